@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+)
+
+// acks — нарушения, подтверждённые оператором через POST /ack. Пока метрика
+// нарушена и подтверждена, внешние получатели о ней не уведомляются;
+// подтверждение снимается при восстановлении. Монитор следит за одним
+// сервером, поэтому ключ — имя метрики; записи многострочного ответа
+// подтверждений не поддерживают.
+type acks struct {
+	mu  sync.Mutex
+	set map[string]bool
+}
+
+func newAcks() *acks {
+	return &acks{set: make(map[string]bool)}
+}
+
+func (a *acks) add(metric string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.set[metric] = true
+}
+
+func (a *acks) acked(metric string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.set[metric]
+}
+
+// clearResolved снимает подтверждения с восстановившихся метрик
+// и возвращает их имена.
+func (a *acks) clearResolved(alerts []Alert) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var cleared []string
+	for _, al := range alerts {
+		if al.Status == statusResolved && al.Record == "" && a.set[al.Metric] {
+			delete(a.set, al.Metric)
+			cleared = append(cleared, al.Metric)
+		}
+	}
+	return cleared
+}
+
+// handleAck: POST /ack?metric=disk подтверждает текущее нарушение метрики.
+func (m *monitor) handleAck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use POST"})
+		return
+	}
+	metric := r.URL.Query().Get("metric")
+	if _, ok := metricTitles[metric]; !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown metric: " + metric})
+		return
+	}
+	if !m.state.breached(metric) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": metric + " is not breached"})
+		return
+	}
+	m.acks.add(metric)
+	m.printf("Alert for %s acknowledged.", metric)
+	writeJSON(w, http.StatusOK, map[string]any{"metric": metric, "acknowledged": true})
+}
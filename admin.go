@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// startAdmin поднимает служебный HTTP-сервер, если задан ADMIN_ADDR.
+func (m *monitor) startAdmin(addr string) {
+	if addr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stats", m.handleStats)
+	mux.HandleFunc("/metrics", m.handleMetrics)
+	mux.HandleFunc("/health", m.handleHealth)
+	mux.HandleFunc("/config", m.handleConfig)
+	mux.HandleFunc("/ready", m.handleReady)
+	mux.HandleFunc("/ack", m.handleAck)
+
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			m.printf("Admin server stopped: %v", err)
+		}
+	}()
+}
+
+type statsResponse struct {
+	Stats           *Stats       `json:"stats"`
+	Derived         *Derived     `json:"derived,omitempty"`
+	UpdatedAt       string       `json:"updated_at,omitempty"`
+	AgeSeconds      *float64     `json:"age_seconds,omitempty"` // с последнего успешного опроса
+	Stale           bool         `json:"stale,omitempty"`       // старше STALE_AFTER
+	HealthScore     *float64     `json:"health_score,omitempty"`
+	DataAgeSeconds  *float64     `json:"data_age_seconds,omitempty"`
+	LoadPercentiles *percentiles `json:"load_percentiles,omitempty"`
+}
+
+// snapshotStale: с STALE_AFTER последний успешный снимок старше порога
+// или его ещё нет. Без STALE_AFTER снимок не устаревает.
+func (m *monitor) snapshotStale(ss []sample, now time.Time) bool {
+	if m.cfg.staleAfter <= 0 {
+		return false
+	}
+	return len(ss) == 0 || now.Sub(ss[len(ss)-1].At) > m.cfg.staleAfter
+}
+
+// handleStats отдаёт последний успешный снимок и при ошибках опроса:
+// возраст показывает age_seconds, 503 — только после STALE_AFTER.
+func (m *monitor) handleStats(w http.ResponseWriter, _ *http.Request) {
+	var resp statsResponse
+	now := time.Now()
+	ss := m.hist.samples()
+	if len(ss) > 0 {
+		last := ss[len(ss)-1]
+		resp.Stats = &last.Stats
+		resp.Derived = &last.Derived
+		resp.UpdatedAt = last.At.UTC().Format(timeFormat)
+		age := now.Sub(last.At).Seconds()
+		resp.AgeSeconds = &age
+		if score, ok := healthScore(last.Stats, m.checkOptions()); ok {
+			resp.HealthScore = &score
+		}
+		if age, ok := dataAge(last.Stats, now); ok {
+			sec := age.Seconds()
+			resp.DataAgeSeconds = &sec
+		}
+	}
+	if p, ok := m.hist.loadPercentiles(); ok {
+		resp.LoadPercentiles = &p
+	}
+	code := http.StatusOK
+	if resp.Stale = m.snapshotStale(ss, now); resp.Stale {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, resp)
+}
+
+type healthResponse struct {
+	Status               string   `json:"status"`
+	ConsecutiveErrors    int64    `json:"consecutive_errors"`
+	LastSuccess          string   `json:"last_success,omitempty"`
+	RetryBudgetRemaining float64  `json:"retry_budget_remaining"` // -1 — без ограничения
+	Flapping             []string `json:"flapping,omitempty"`     // см. FLAP_THRESHOLD
+	ErrorRate            float64  `json:"error_rate"`             // доля неудачных опросов за ERROR_RATE_WINDOW
+	ErrorRatePolls       int      `json:"error_rate_polls"`
+}
+
+// handleHealth: 200, пока опросы успешны; 503 после трёх ошибок подряд,
+// а с STALE_AFTER — когда последний успешный снимок старше порога.
+func (m *monitor) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	resp := healthResponse{
+		Status:               "ok",
+		ConsecutiveErrors:    m.consecutiveErrors.Load(),
+		RetryBudgetRemaining: m.budget.remaining(),
+		Flapping:             m.flap.current(),
+	}
+	resp.ErrorRate, resp.ErrorRatePolls = m.errRate.rate(time.Now())
+	ss := m.hist.samples()
+	if len(ss) > 0 {
+		resp.LastSuccess = ss[len(ss)-1].At.UTC().Format(timeFormat)
+	}
+	unhealthy := resp.ConsecutiveErrors >= 3
+	if m.cfg.staleAfter > 0 {
+		unhealthy = m.snapshotStale(ss, time.Now())
+	}
+	code := http.StatusOK
+	if unhealthy {
+		resp.Status = "unhealthy"
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, resp)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const metricDataAge = "data_age"
+
+// dataAge — возраст данных по серверному timestamp; отрицательный,
+// если часы сервера убежали вперёд.
+func dataAge(s Stats, now time.Time) (time.Duration, bool) {
+	ts, ok := s.Timestamp()
+	if !ok {
+		return 0, false
+	}
+	return now.Sub(ts), true
+}
+
+// staleness сообщает об устаревших данных один раз за эпизод.
+type staleness struct {
+	maxAge time.Duration // 0 — проверка выключена
+	warned bool
+}
+
+func (st *staleness) check(s Stats, now time.Time) (Alert, bool) {
+	age, ok := dataAge(s, now)
+	if st.maxAge == 0 || !ok {
+		return Alert{}, false
+	}
+	if age.Abs() <= st.maxAge {
+		st.warned = false
+		return Alert{}, false
+	}
+	if st.warned {
+		return Alert{}, false
+	}
+	st.warned = true
+
+	msg := fmt.Sprintf("Stats data is stale: %s old", formatDuration(age))
+	if age < 0 {
+		msg = fmt.Sprintf("Stats timestamp is %s ahead of local clock", formatDuration(-age))
+	}
+	return Alert{
+		Metric:    metricDataAge,
+		Status:    statusFiring,
+		Severity:  severityWarn,
+		Message:   msg,
+		Value:     age.Seconds(),
+		Threshold: st.maxAge.Seconds(),
+		Time:      now,
+	}, true
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// batchNotifier — получатель, принимающий пачку алертов одним сообщением.
+type batchNotifier interface {
+	NotifyBatch([]Alert) error
+}
+
+// batcher копит алерты в течение окна и отдаёт их получателю одной пачкой.
+// На метрику хранится последнее событие; восстановление отменяет
+// ещё не отправленный алерт той же метрики.
+type batcher struct {
+	window time.Duration
+	next   Notifier
+	logf   func(format string, args ...any)
+
+	mu      sync.Mutex
+	pending map[string]Alert
+	sent    map[string]bool // по метрике уже ушёл firing
+	timer   *time.Timer
+}
+
+func newBatcher(window time.Duration, next Notifier, logf func(format string, args ...any)) *batcher {
+	return &batcher{
+		window:  window,
+		next:    next,
+		logf:    logf,
+		pending: make(map[string]Alert),
+		sent:    make(map[string]bool),
+	}
+}
+
+func (b *batcher) Notify(a Alert) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if a.Status == statusResolved && !b.sent[a.Metric] {
+		// О нарушении никто не узнал — и о восстановлении сообщать незачем
+		delete(b.pending, a.Metric)
+		return nil
+	}
+	b.pending[a.Metric] = a
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.window, b.flush)
+	}
+	return nil
+}
+
+func (b *batcher) flush() {
+	b.mu.Lock()
+	batch := make([]Alert, 0, len(b.pending))
+	// Правила ALERT_RULES — после встроенных метрик, по имени
+	metrics := slices.Concat(allMetrics, eventMetrics)
+	var rules []string
+	for metric := range b.pending {
+		if !slices.Contains(metrics, metric) {
+			rules = append(rules, metric)
+		}
+	}
+	slices.Sort(rules)
+	for _, metric := range append(metrics, rules...) {
+		a, ok := b.pending[metric]
+		if !ok {
+			continue
+		}
+		batch = append(batch, a)
+		b.sent[metric] = a.Status == statusFiring
+	}
+	clear(b.pending)
+	b.timer = nil
+	b.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+	if err := b.deliver(batch); err != nil {
+		b.logf("Batched notification failed: %v", err)
+	}
+}
+
+// drain отправляет накопленное, не дожидаясь окна.
+func (b *batcher) drain(timeout time.Duration) {
+	b.mu.Lock()
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	b.mu.Unlock()
+	b.flush()
+	if d, ok := b.next.(drainer); ok {
+		d.drain(timeout)
+	}
+}
+
+func (b *batcher) deliver(batch []Alert) error {
+	if bn, ok := b.next.(batchNotifier); ok {
+		return bn.NotifyBatch(batch)
+	}
+	for _, a := range batch {
+		if err := safeNotify(b.next, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+// capacityMetrics — метрики с полем total в порядке ewma.ratios.
+var capacityMetrics = [...]string{metricMem, metricDisk, metricNet}
+
+// capacityWatch замечает смену total между соседними успешными опросами
+// (диск расширили, поменяли объём памяти): накопленная по долям база
+// после этого неверна. Используется только из цикла опроса.
+type capacityWatch struct {
+	enabled bool
+	primed  bool
+	totals  [len(capacityMetrics)]uint64
+}
+
+type capacityChange struct {
+	index    int // в capacityMetrics
+	from, to uint64
+}
+
+// observe возвращает метрики, у которых сменился total с прошлого опроса.
+func (c *capacityWatch) observe(s Stats) []capacityChange {
+	if !c.enabled {
+		return nil
+	}
+	var changed []capacityChange
+	totals := [len(capacityMetrics)]uint64{s.TotalRAM, s.TotalDisk, s.NetCapacity}
+	if c.primed {
+		for i, t := range totals {
+			if t != c.totals[i] {
+				changed = append(changed, capacityChange{index: i, from: c.totals[i], to: t})
+			}
+		}
+	}
+	c.totals = totals
+	c.primed = true
+	return changed
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const capturePrefix = "body-"
+
+// captureBody сохраняет тело ответа, которое не удалось разобрать, в
+// CAPTURE_ON_ERROR_DIR. Хранится не больше CAPTURE_MAX_FILES последних
+// файлов: самые старые удаляются. Чужие файлы в каталоге не трогаются.
+func (m *monitor) captureBody(body string) {
+	dir := m.cfg.captureDir
+	if dir == "" {
+		return
+	}
+	name := capturePrefix + time.Now().UTC().Format("20060102T150405.000000000Z") + ".txt"
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+		m.printf("Unable to capture response body: %v", err)
+		return
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var captured []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), capturePrefix) && strings.HasSuffix(e.Name(), ".txt") {
+			captured = append(captured, e.Name())
+		}
+	}
+	slices.Sort(captured) // имя начинается со времени, поэтому сортировка хронологическая
+	for len(captured) > m.cfg.captureMaxFiles {
+		_ = os.Remove(filepath.Join(dir, captured[0]))
+		captured = captured[1:]
+	}
+}
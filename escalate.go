@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// warnMetrics — метрики с уровнем warning (WARN_*): только их есть куда повышать.
+var warnMetrics = []string{metricLoad, metricMem, metricDisk, metricNet}
+
+// parseEscalate разбирает ESCALATE_AFTER: "30m" — для всех метрик с WARN_*,
+// "mem=30m,disk=1h" — по метрикам. "" — повышения нет.
+func parseEscalate(v string) (map[string]time.Duration, error) {
+	if v == "" {
+		return nil, nil
+	}
+	after := make(map[string]time.Duration)
+	if !strings.Contains(v, "=") {
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid duration %q", v)
+		}
+		for _, metric := range warnMetrics {
+			after[metric] = d
+		}
+		return after, nil
+	}
+	for _, pair := range strings.Split(v, ",") {
+		name, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("expected metric=duration, got %q", pair)
+		}
+		if !slices.Contains(warnMetrics, name) {
+			return nil, fmt.Errorf("metric %q has no warning level", name)
+		}
+		d, err := time.ParseDuration(val)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid duration for %s: %q", name, val)
+		}
+		after[name] = d
+	}
+	return after, nil
+}
+
+func formatEscalate(after map[string]time.Duration) string {
+	var parts []string
+	for _, metric := range warnMetrics {
+		if d, ok := after[metric]; ok {
+			parts = append(parts, metric+"="+d.String())
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// escalate повышает затянувшееся предупреждение до критического. Повышенная
+// метрика остаётся CRIT, пока держится хотя бы WARN; после восстановления
+// отсчёт начинается заново. Вызывается под t.mu до обновления st.
+func (t *tracker) escalate(a *Alert, st *metricState, now time.Time) {
+	if a.Severity != severityWarn {
+		st.escalated = false // настоящий CRIT
+		return
+	}
+	after := t.escalateAfter[a.Metric]
+	if after <= 0 {
+		return
+	}
+	if !st.escalated && (st.severity != severityWarn || st.levelAt.IsZero() || now.Sub(st.levelAt) < after) {
+		return
+	}
+	st.escalated = true
+	a.Severity = severityCrit
+	a.Message += fmt.Sprintf(" (escalated: WARN for over %s)", formatDuration(after))
+}
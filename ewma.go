@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math"
+	"strconv"
+)
+
+// ewma сглаживает load average и доли used/total экспоненциальным скользящим
+// средним: e = α·x + (1−α)·e₍ₙ₋₁₎. Используется только из цикла опроса.
+type ewma struct {
+	alpha       float64 // 0 — сглаживание выключено
+	primed      bool
+	load        float64
+	ratios      [len(capacityMetrics)]float64 // RAM, диск, сеть
+	ratioPrimed [len(capacityMetrics)]bool
+}
+
+// reset забывает сглаженную долю метрики i: следующее значение берётся как есть.
+func (e *ewma) reset(i int) {
+	e.ratioPrimed[i] = false
+}
+
+func (e *ewma) next(prev, x float64) float64 {
+	return e.alpha*x + (1-e.alpha)*prev
+}
+
+// apply возвращает копию s со сглаженными значениями: load average и used
+// пересчитываются из сглаженных величин, total остаётся как пришёл. Пороги
+// и сообщения дальше работают со сглаженными значениями.
+func (e *ewma) apply(s Stats) Stats {
+	if e.alpha <= 0 {
+		return s
+	}
+	pairs := [...]struct{ used, total *uint64 }{
+		{&s.UsedRAM, &s.TotalRAM},
+		{&s.UsedDisk, &s.TotalDisk},
+		{&s.NetUsed, &s.NetCapacity},
+	}
+	for i, p := range pairs {
+		var r float64
+		if *p.total > 0 {
+			r = float64(*p.used) / float64(*p.total)
+		}
+		if e.ratioPrimed[i] {
+			r = e.next(e.ratios[i], r)
+		}
+		e.ratios[i] = r
+		e.ratioPrimed[i] = true
+		*p.used = uint64(math.Round(r * float64(*p.total)))
+	}
+
+	load := s.LoadAvg
+	if e.primed {
+		load = e.next(e.load, load)
+	}
+	e.load = load
+	e.primed = true
+
+	if load != s.LoadAvg {
+		s.LoadAvg = load
+		s.LoadRaw = trimTrailingZeros(strconv.FormatFloat(load, 'f', 2, 64))
+	}
+	return s
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestEWMAKnownAlpha(t *testing.T) {
+	e := ewma{alpha: 0.5}
+	steps := []struct {
+		load, used  float64
+		wantLoad    float64
+		wantLoadRaw string
+		wantUsed    uint64
+	}{
+		{load: 10, used: 0, wantLoad: 10, wantLoadRaw: "10", wantUsed: 0}, // первое значение — как есть
+		{load: 20, used: 100, wantLoad: 15, wantLoadRaw: "15", wantUsed: 50},
+		{load: 40, used: 100, wantLoad: 27.5, wantLoadRaw: "27.5", wantUsed: 75},
+		{load: 27.5, used: 25, wantLoad: 27.5, wantLoadRaw: "27.5", wantUsed: 50},
+	}
+	for i, st := range steps {
+		in := Stats{LoadAvg: st.load, LoadRaw: strconv.FormatFloat(st.load, 'f', -1, 64), UsedRAM: uint64(st.used), TotalRAM: 100}
+		got := e.apply(in)
+		if got.LoadAvg != st.wantLoad || got.LoadRaw != st.wantLoadRaw || got.UsedRAM != st.wantUsed {
+			t.Errorf("step %d: load %v (%q), used %d; want %v (%q), %d",
+				i, got.LoadAvg, got.LoadRaw, got.UsedRAM, st.wantLoad, st.wantLoadRaw, st.wantUsed)
+		}
+		if got.TotalRAM != 100 {
+			t.Errorf("step %d: total changed to %d", i, got.TotalRAM)
+		}
+	}
+}
+
+func TestEWMADisabledAndReset(t *testing.T) {
+	s := Stats{LoadAvg: 3, LoadRaw: "3", UsedRAM: 80, TotalRAM: 100}
+	var off ewma
+	off.apply(Stats{LoadAvg: 1, UsedRAM: 10, TotalRAM: 100})
+	if got := off.apply(s); !reflect.DeepEqual(got, s) {
+		t.Errorf("alpha 0 changed stats: %+v", got)
+	}
+
+	e := ewma{alpha: 0.25}
+	e.apply(Stats{UsedRAM: 0, TotalRAM: 100})
+	if got := e.apply(Stats{UsedRAM: 100, TotalRAM: 100}); got.UsedRAM != 25 {
+		t.Errorf("used after 0 then 100 at alpha 0.25 = %d, want 25", got.UsedRAM)
+	}
+	e.reset(0) // RAM: сглаживание заново
+	if got := e.apply(Stats{UsedRAM: 100, TotalRAM: 200}); got.UsedRAM != 100 {
+		t.Errorf("used after reset = %d, want 100 as is", got.UsedRAM)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// fetchAny опрашивает основной адрес, а при ошибке — сразу запасной.
+// В sticky-режиме после переключения первым пробуется запасной,
+// пока он не откажет.
+func (m *monitor) fetchAny() (string, error) {
+	urls := []string{m.cfg.request.url}
+	if m.cfg.fallbackURL != "" {
+		urls = append(urls, m.cfg.fallbackURL)
+		if m.cfg.fallbackSticky && m.preferFallback {
+			urls[0], urls[1] = urls[1], urls[0]
+		}
+	}
+
+	var errs []error
+	for _, u := range urls {
+		req := m.cfg.request
+		req.url = u
+		req.correlationID = m.pollID
+		if err := m.limiter.Wait(m.ctx); err != nil {
+			return "", err
+		}
+		body, err := m.fetchAuthorized(req)
+		if errors.Is(err, errNoData) {
+			m.servedBy(u)
+			return "", err
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", u, err))
+			continue
+		}
+		m.servedBy(u)
+		return body, nil
+	}
+	if len(errs) == 1 {
+		return "", errs[0]
+	}
+	return "", fmt.Errorf("all endpoints failed: %w; %w", errs[0], errs[1])
+}
+
+// servedBy пишет в лог смену адреса, с которого пришла статистика.
+func (m *monitor) servedBy(u string) {
+	if m.cfg.fallbackURL == "" {
+		return
+	}
+	m.preferFallback = u == m.cfg.fallbackURL
+	if u == m.lastServedBy {
+		return
+	}
+	if m.lastServedBy != "" || m.preferFallback {
+		m.printf("Stats served by %s", u)
+	}
+	m.lastServedBy = u
+}
+
+// fetchAuthorized добавляет к запросу bearer-токен. Если сервер отверг
+// токен (401), кэш сбрасывается и запрос один раз повторяется с новым.
+func (m *monitor) fetchAuthorized(req statsRequest) (string, error) {
+	var err error
+	if req.bearer, err = m.bearer(); err != nil {
+		return "", err
+	}
+	body, err := fetchBody(m.ctx, m.client, req)
+	var se *statusError
+	if m.auth == nil || !errors.As(err, &se) || se.code != http.StatusUnauthorized {
+		return body, err
+	}
+	m.auth.invalidate()
+	if req.bearer, err = m.bearer(); err != nil {
+		return "", err
+	}
+	return fetchBody(m.ctx, m.client, req)
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stdinURL в STATS_URL означает чтение одной строки из stdin.
+const stdinURL = "-"
+
+// statsRequest описывает, как запрашивать статистику.
+type statsRequest struct {
+	url          string
+	method       string
+	body         string
+	contentType  string
+	acceptStatus []int
+	readTimeout  time.Duration  // дедлайн запроса вместе с чтением тела; 0 — только HTTP_TIMEOUT_MS
+	okPattern    *regexp.Regexp // BODY_OK_PATTERN; nil — любое тело
+	skipEmpty    bool           // EMPTY_BODY=skip
+
+	correlationID string // X-Correlation-ID; задаётся на каждый опрос
+	bearer        string // токен OAuth2; задаётся на каждый запрос
+}
+
+// errNoData — сервер ответил допустимым кодом без тела (например, 204):
+// новых данных нет, но и ошибкой получения это не считается.
+var errNoData = errors.New("no new data")
+
+// Значения EMPTY_BODY: что значит пустое тело с кодом 200
+const (
+	emptyBodyError = "error" // ошибка разбора "empty body"
+	emptyBodySkip  = "skip"  // новых данных нет, как 204
+)
+
+func emptyBodyPolicy(skip bool) string {
+	if skip {
+		return emptyBodySkip
+	}
+	return emptyBodyError
+}
+
+// noData: пустое тело без кода 200 — всегда «данных нет», с кодом 200 —
+// только при EMPTY_BODY=skip.
+func noData(code int, body []byte, skipEmpty bool) bool {
+	return len(bytes.TrimSpace(body)) == 0 && (code != http.StatusOK || skipEmpty)
+}
+
+// statusError — код ответа не входит в ACCEPT_STATUS.
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return "bad status: " + e.status
+}
+
+// retryable: 4xx, кроме 408 и 429, повтором не лечатся.
+func retryable(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= 500 || se.code == http.StatusRequestTimeout || se.code == http.StatusTooManyRequests
+	}
+	return !errors.Is(err, errNoData)
+}
+
+func validateURL(raw string) error {
+	if u, err := url.Parse(raw); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid URL %q", raw)
+	}
+	return nil
+}
+
+// parseQuery читает STATS_QUERY: "group=system,fields=all". Значения
+// экранирует url.Values, заранее кодировать их не нужно.
+func parseQuery(v string) (url.Values, error) {
+	if v == "" {
+		return nil, nil
+	}
+	q := make(url.Values)
+	for _, pair := range strings.Split(v, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", pair)
+		}
+		q.Add(key, val)
+	}
+	return q, nil
+}
+
+// mergeQuery дописывает параметры q к адресу; одноимённые параметры
+// из адреса заменяются значениями из q.
+func mergeQuery(raw string, q url.Values) (string, error) {
+	if len(q) == 0 {
+		return raw, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %q", raw)
+	}
+	merged := u.Query()
+	for key, vals := range q {
+		merged[key] = vals
+	}
+	u.RawQuery = merged.Encode()
+	return u.String(), nil
+}
+
+func (r statsRequest) validate() error {
+	if r.url == stdinURL {
+		return nil
+	}
+	for _, code := range r.acceptStatus {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("ACCEPT_STATUS: invalid status code %d", code)
+		}
+	}
+	switch r.method {
+	case http.MethodGet, http.MethodHead, http.MethodTrace:
+		if r.body != "" {
+			return fmt.Errorf("STATS_BODY is not allowed with %s", r.method)
+		}
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+	default:
+		return fmt.Errorf("unsupported STATS_METHOD %q", r.method)
+	}
+	return nil
+}
+
+func parseStatusList(v string) ([]int, error) {
+	var codes []int
+	for _, item := range strings.Split(v, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status code %q", item)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+func formatStatusList(codes []int) string {
+	parts := make([]string, len(codes))
+	for i, code := range codes {
+		parts[i] = strconv.Itoa(code)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (r statsRequest) build() (*http.Request, error) {
+	var body io.Reader
+	if r.body != "" {
+		body = strings.NewReader(r.body)
+	}
+	req, err := http.NewRequest(r.method, r.url, body)
+	if err != nil {
+		return nil, err
+	}
+	if r.body != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	if r.correlationID != "" {
+		req.Header.Set(correlationHeader, r.correlationID)
+	}
+	if r.bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+r.bearer)
+	}
+	return req, nil
+}
+
+// newHTTPClient собирает клиент для запросов статистики. CONNECT_TIMEOUT_MS
+// ограничивает установку соединения, READ_TIMEOUT_MS — весь запрос через
+// дедлайн контекста (см. fetchBody) и заменяет HTTP_TIMEOUT_MS. Без
+// READ_TIMEOUT_MS общий предел — HTTP_TIMEOUT_MS, и он же накрывает connect.
+// onUpgrade вызывается при переадресации с http на https.
+func newHTTPClient(c config, onUpgrade func(to *url.URL)) *http.Client {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if c.connectTimeout > 0 {
+		tr.DialContext = (&net.Dialer{Timeout: c.connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	}
+	if c.tlsConfig != nil {
+		tr.TLSClientConfig = c.tlsConfig.Clone()
+	}
+	client := &http.Client{Transport: tr, Timeout: c.httpTimeout}
+	if c.request.readTimeout > 0 {
+		client.Timeout = 0
+	}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		if via[len(via)-1].URL.Scheme == "http" && req.URL.Scheme == "https" && onUpgrade != nil {
+			onUpgrade(req.URL)
+		}
+		if !c.followRedirects {
+			return fmt.Errorf("redirect to %s not followed (FOLLOW_REDIRECTS=false)", req.URL.Redacted())
+		}
+		return nil
+	}
+	return client
+}
+
+// httpsRedirect один раз подсказывает сменить адрес: каждый опрос иначе
+// платит за лишний запрос, а сбой TLS после переадресации выглядит загадочно.
+func (m *monitor) httpsRedirect(to *url.URL) {
+	if m.httpsHinted.CompareAndSwap(false, true) {
+		m.printf("Stats URL redirects to %s: update STATS_URL to the https address.", to.Redacted())
+	}
+}
+
+const maxRedirects = 10 // как у http.Client по умолчанию
+
+// loadTLSConfig: TLS_CA_FILE добавляет доверенные CA к системным,
+// TLS_INSECURE отключает проверку сертификата. nil — настройки по умолчанию.
+func loadTLSConfig(caFile string, insecure bool) (*tls.Config, error) {
+	if caFile == "" && !insecure {
+		return nil, nil
+	}
+	cfg := &tls.Config{InsecureSkipVerify: insecure}
+	if caFile == "" {
+		return cfg, nil
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.RootCAs, err = x509.SystemCertPool(); err != nil {
+		cfg.RootCAs = x509.NewCertPool()
+	}
+	if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s: no PEM certificates found", caFile)
+	}
+	return cfg, nil
+}
+
+// fetchBody выполняет запрос в рамках ctx: отмена (завершение процесса)
+// прерывает и соединение, и чтение тела.
+func fetchBody(ctx context.Context, client *http.Client, sr statsRequest) (string, error) {
+	req, err := sr.build()
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	if sr.readTimeout > 0 {
+		ctx, cancel := context.WithTimeout(ctx, sr.readTimeout)
+		defer cancel() // после чтения тела
+		req = req.WithContext(ctx)
+	}
+
+	resp, err := client.Do(req)
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) {
+		return "", fmt.Errorf("%w (trusted CAs can be added with TLS_CA_FILE)", err)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if !slices.Contains(sr.acceptStatus, resp.StatusCode) {
+		return "", &statusError{code: resp.StatusCode, status: resp.Status}
+	}
+
+	r, err := decodeBody(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return "", err
+	}
+
+	// ReadAll читает до EOF, поэтому тело, пришедшее несколькими чанками
+	// или без завершающего \n, собирается целиком.
+	body, err := io.ReadAll(r)
+	// Прокси может заявить Content-Length больше настоящего тела и закрыть
+	// соединение раньше. Полученное разбирается, только если оно кончается
+	// переводом строки: обрыв посреди числа («…,950» → «…,95») разбор не
+	// заметит. Ничего не пришло или строка оборвана — ошибка.
+	if errors.Is(err, io.ErrUnexpectedEOF) && len(bytes.TrimSpace(body)) > 0 && bytes.HasSuffix(body, []byte("\n")) {
+		err = nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("read body: %w", err)
+	}
+	if noData(resp.StatusCode, body, sr.skipEmpty) {
+		return "", errNoData
+	}
+	return sr.checkBody(string(body))
+}
+
+// checkBody сверяет тело с BODY_OK_PATTERN. Несовпадение — ошибка получения,
+// тело не разбирается. Совпадение с начала тела (`^OK,`) отрезается,
+// остаток разбирается как обычно.
+func (r statsRequest) checkBody(body string) (string, error) {
+	if r.okPattern == nil {
+		return body, nil
+	}
+	loc := r.okPattern.FindStringIndex(body)
+	if loc == nil {
+		head := strings.TrimSpace(body)
+		if len(head) > maxErrorValueLen {
+			head = head[:maxErrorValueLen] + "..."
+		}
+		return "", fmt.Errorf("body does not match BODY_OK_PATTERN: %q", head)
+	}
+	if loc[0] == 0 {
+		body = body[loc[1]:]
+	}
+	return body, nil
+}
+
+// readLine читает первую строку r; завершающий \n не обязателен.
+func readLine(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("read stdin: %w", err)
+	}
+	return line, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testBody = "1.5,8000,1000,100000000000,1000000000,1000000000,100000000"
+
+// testRequest — GET к srv с кодом 200, как при настройках по умолчанию.
+func testRequest(srv *httptest.Server) statsRequest {
+	return statsRequest{url: srv.URL, acceptStatus: []int{http.StatusOK}}
+}
+
+// fetchParsed получает тело через fetchBody и разбирает его csv-парсером.
+func fetchParsed(t *testing.T, h http.HandlerFunc) Stats {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	body, err := fetchBody(context.Background(), srv.Client(), testRequest(srv))
+	if err != nil {
+		t.Fatalf("fetchBody: %v", err)
+	}
+	if body != testBody {
+		t.Fatalf("body = %q, want %q", body, testBody)
+	}
+	s, err := ParseStats(body, parseOptions{maxLoadAvg: defaultMaxLoadAvg, maxRatio: defaultMaxRatio})
+	if err != nil {
+		t.Fatalf("ParseStats: %v", err)
+	}
+	return s
+}
+
+// Один чанк без Content-Length и без завершающего \n: Flush до выхода
+// из обработчика не даёт серверу посчитать длину.
+func TestFetchBodySingleChunkWithoutNewline(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, testBody)
+		w.(http.Flusher).Flush()
+	}
+	srv := httptest.NewServer(http.HandlerFunc(h))
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	srv.Close()
+	if resp.ContentLength != -1 || !slices.Equal(resp.TransferEncoding, []string{"chunked"}) {
+		t.Fatalf("response is not chunked: length %d, encoding %v", resp.ContentLength, resp.TransferEncoding)
+	}
+
+	s := fetchParsed(t, h)
+	if s.NetUsed != 100000000 {
+		t.Errorf("net used = %d, want 100000000: last field lost", s.NetUsed)
+	}
+}
+
+// Тело приходит несколькими чанками, разрезанными посреди чисел, с паузой
+// между ними: fetchBody читает до конца ответа, а не до первого чанка.
+func TestFetchBodyMultipleChunks(t *testing.T) {
+	chunks := []string{"1.", "5,8000,10", "00,100000000000,1000000000,", "1000000000,1000", "00000"}
+	s := fetchParsed(t, func(w http.ResponseWriter, r *http.Request) {
+		for _, c := range chunks {
+			io.WriteString(w, c)
+			w.(http.Flusher).Flush()
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+	want := Stats{LoadAvg: 1.5, LoadRaw: "1.5", TotalRAM: 8000, UsedRAM: 1000, TotalDisk: 100000000000,
+		UsedDisk: 1000000000, NetCapacity: 1000000000, NetUsed: 100000000}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("stats = %+v, want %+v", s, want)
+	}
+}
+
+// prematureClose заявляет Content-Length больше тела и закрывает
+// соединение, отдав только body.
+func prematureClose(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)+100))
+		io.WriteString(w, body)
+	}
+}
+
+func TestFetchBodyPrematureCloseKeepsCompleteLine(t *testing.T) {
+	srv := httptest.NewServer(prematureClose(testBody + "\n"))
+	defer srv.Close()
+	body, err := fetchBody(context.Background(), srv.Client(), testRequest(srv))
+	if err != nil {
+		t.Fatalf("fetchBody: %v", err)
+	}
+	if body != testBody+"\n" {
+		t.Errorf("body = %q, want %q", body, testBody+"\n")
+	}
+}
+
+// Пустое тело или строка, оборванная без перевода строки (посреди числа), —
+// ошибка: иначе «…,100000000» обрезанное до «…,1000» разобралось бы молча.
+func TestFetchBodyPrematureCloseEmptyOrCutIsError(t *testing.T) {
+	for _, body := range []string{"", "\n", testBody, testBody[:len(testBody)-5]} {
+		srv := httptest.NewServer(prematureClose(body))
+		_, err := fetchBody(context.Background(), srv.Client(), testRequest(srv))
+		srv.Close()
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("body %q: err = %v, want unexpected EOF", body, err)
+		}
+	}
+}
+
+func TestCheckBodyOKPattern(t *testing.T) {
+	cases := []struct {
+		pattern, body, want, wantErr string
+	}{
+		{pattern: "", body: testBody, want: testBody},
+		{pattern: "^OK,", body: "OK," + testBody, want: testBody},
+		{pattern: "ok$", body: testBody + ",ok", want: testBody + ",ok"}, // не с начала — тело как есть
+		{pattern: "^OK,", body: "ERR,maintenance", wantErr: `body does not match BODY_OK_PATTERN: "ERR,maintenance"`},
+		{pattern: "^OK,", body: "", wantErr: `body does not match BODY_OK_PATTERN: ""`},
+		{pattern: "^OK,", body: "maintenance window until 06:00 UTC, retry later", wantErr: `body does not match BODY_OK_PATTERN: "maintenance window until 06:00 U..."`},
+	}
+	for _, c := range cases {
+		var req statsRequest
+		if c.pattern != "" {
+			req.okPattern = regexp.MustCompile(c.pattern)
+		}
+		got, err := req.checkBody(c.body)
+		switch {
+		case c.wantErr != "":
+			if err == nil || err.Error() != c.wantErr {
+				t.Errorf("%q on %q: err = %v, want %s", c.pattern, c.body, err, c.wantErr)
+			}
+		case err != nil:
+			t.Errorf("%q on %q: %v", c.pattern, c.body, err)
+		case got != c.want:
+			t.Errorf("%q on %q: body = %q, want %q", c.pattern, c.body, got, c.want)
+		}
+	}
+}
+
+// Несовпадение отсекается ещё в fetchBody: до разбора тело не доходит.
+func TestFetchBodyRejectsBodyNotMatchingOKPattern(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ERR,"+testBody)
+	}))
+	defer srv.Close()
+	req := testRequest(srv)
+	req.okPattern = regexp.MustCompile("^OK,")
+	if body, err := fetchBody(context.Background(), srv.Client(), req); err == nil {
+		t.Errorf("fetchBody = %q, want BODY_OK_PATTERN error", body)
+	}
+}
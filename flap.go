@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const statusFlapping = "flapping"
+
+// flapDetector замечает метрики, которые слишком часто переходят между
+// нарушением и нормой: больше threshold переходов за window. Такая метрика
+// получает одно уведомление, её алерты и восстановления не рассылаются, пока
+// за целое окно не случится ни одного перехода. Ключ — "метрика" или
+// "запись/метрика". Состояние читается из /health.
+type flapDetector struct {
+	threshold int // 0 — выключено
+	window    time.Duration
+
+	mu          sync.Mutex
+	transitions map[string][]time.Time
+	flapping    map[string]Alert // уведомление о начале флапа
+}
+
+func newFlapDetector(threshold int, window time.Duration) *flapDetector {
+	return &flapDetector{
+		threshold:   threshold,
+		window:      window,
+		transitions: make(map[string][]time.Time),
+		flapping:    make(map[string]Alert),
+	}
+}
+
+func flapKey(a Alert) string {
+	if a.Record != "" {
+		return a.Record + "/" + a.Metric
+	}
+	return a.Metric
+}
+
+// isTransition: трекер ставит Since = Time в опросе, где нарушение началось.
+func isTransition(a Alert) bool {
+	return a.Status == statusResolved || (a.Status == statusFiring && a.Since.Equal(a.Time))
+}
+
+// filter учитывает переходы опроса и возвращает алерты без флапающих метрик,
+// с уведомлениями о начале флапа. stable — сообщения о метриках, которые успокоились.
+func (d *flapDetector) filter(alerts []Alert, now time.Time) (out []Alert, stable []string) {
+	if d.threshold <= 0 {
+		return alerts, nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, a := range alerts {
+		if isTransition(a) {
+			key := flapKey(a)
+			d.transitions[key] = append(d.transitions[key], now)
+		}
+	}
+	for key, ts := range d.transitions {
+		for len(ts) > 0 && now.Sub(ts[0]) > d.window {
+			ts = ts[1:]
+		}
+		d.transitions[key] = ts
+		if len(ts) == 0 {
+			delete(d.transitions, key)
+			if a, ok := d.flapping[key]; ok {
+				delete(d.flapping, key)
+				stable = append(stable, labelPrefix(a.Record)+metricTitle(a.Metric)+" stopped flapping.")
+			}
+		}
+	}
+	sort.Strings(stable)
+
+	for _, a := range alerts {
+		key := flapKey(a)
+		if _, ok := d.flapping[key]; ok {
+			continue
+		}
+		n := len(d.transitions[key])
+		if n <= d.threshold {
+			out = append(out, a)
+			continue
+		}
+		notice := Alert{
+			Metric:  a.Metric,
+			Status:  statusFlapping,
+			Message: fmt.Sprintf("%s%s is flapping: %d state changes in %s", labelPrefix(a.Record), metricTitle(a.Metric), n, formatDuration(d.window)),
+			Time:    now,
+			Record:  a.Record,
+		}
+		d.flapping[key] = notice
+		out = append(out, notice)
+	}
+	return out, stable
+}
+
+// current — ключи метрик, флапающих сейчас, по алфавиту.
+func (d *flapDetector) current() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	keys := make([]string, 0, len(d.flapping))
+	for key := range d.flapping {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
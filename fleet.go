@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fleetDedup схлопывает одинаковые алерты разных серверов HOSTS: алерты
+// одной метрики, статуса и уровня, пришедшие в течение окна, уходят
+// внешнему получателю одним сообщением со списком серверов. Так общий
+// сбой (например, хранилища) не даёт по алерту на каждый сервер.
+// В отличие от NOTIFY_BATCH_WINDOW работает поперёк серверов.
+type fleetDedup struct {
+	window time.Duration
+	logf   func(format string, args ...any)
+
+	mu     sync.Mutex
+	groups map[dedupKey]*dedupGroup
+}
+
+type dedupKey struct {
+	sink, metric, record, status, severity string
+}
+
+type dedupGroup struct {
+	next   Notifier // получатель сервера, первым попавшего в группу
+	alerts []Alert  // по алерту на сервер, последний
+	timer  *time.Timer
+}
+
+func newFleetDedup(window time.Duration, logf func(format string, args ...any)) *fleetDedup {
+	return &fleetDedup{window: window, logf: logf, groups: make(map[dedupKey]*dedupGroup)}
+}
+
+// dedupNotifier — получатель сервера, подключённый к общему fleetDedup.
+type dedupNotifier struct {
+	d    *fleetDedup
+	sink string
+	next Notifier
+}
+
+func (d *fleetDedup) wrap(sink string, next Notifier) Notifier {
+	return dedupNotifier{d: d, sink: sink, next: next}
+}
+
+func (n dedupNotifier) Notify(a Alert) error {
+	key := dedupKey{sink: n.sink, metric: a.Metric, record: a.Record, status: a.Status, severity: a.Severity}
+	d := n.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	g := d.groups[key]
+	if g == nil {
+		g = &dedupGroup{next: n.next}
+		g.timer = time.AfterFunc(d.window, func() { d.flush(key) })
+		d.groups[key] = g
+	}
+	i := slices.IndexFunc(g.alerts, func(b Alert) bool { return b.Server == a.Server })
+	if i >= 0 {
+		g.alerts[i] = a
+	} else {
+		g.alerts = append(g.alerts, a)
+	}
+	return nil
+}
+
+// drain при завершении отправляет все группы, не дожидаясь окна.
+func (n dedupNotifier) drain(timeout time.Duration) {
+	n.d.mu.Lock()
+	keys := make([]dedupKey, 0, len(n.d.groups))
+	for key, g := range n.d.groups {
+		g.timer.Stop()
+		keys = append(keys, key)
+	}
+	n.d.mu.Unlock()
+	for _, key := range keys {
+		n.d.flush(key)
+	}
+	if d, ok := n.next.(drainer); ok {
+		d.drain(timeout)
+	}
+}
+
+func (d *fleetDedup) flush(key dedupKey) {
+	d.mu.Lock()
+	g := d.groups[key]
+	delete(d.groups, key)
+	d.mu.Unlock()
+	if g == nil {
+		return // уже отправлена drain
+	}
+	if err := safeNotify(g.next, mergeFleetAlerts(g.alerts)); err != nil {
+		d.logf("Notifier %s failed: %v", key.sink, err)
+	}
+}
+
+// mergeFleetAlerts: алерт одного сервера уходит как есть; у нескольких —
+// первый с перечнем серверов: "Free disk space is too low: 4768 Mb left (on 3 servers: web1, web2, web3)".
+func mergeFleetAlerts(alerts []Alert) Alert {
+	a := alerts[0]
+	if len(alerts) == 1 {
+		return a
+	}
+	a.Servers = make([]string, len(alerts))
+	for i, b := range alerts {
+		a.Servers[i] = b.Server
+	}
+	slices.Sort(a.Servers)
+	a.Message += fmt.Sprintf(" (on %d servers: %s)", len(a.Servers), strings.Join(a.Servers, ", "))
+	a.Server, a.CorrelationID = "", ""
+	return a
+}
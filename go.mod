@@ -1,3 +1,12 @@
 module RedStivens/go-magistr-lesson1-levmaksim
 
-go 1.22
\ No newline at end of file
+go 1.22
+
+require (
+	github.com/andybalholm/brotli v1.2.5
+	github.com/expr-lang/expr v1.17.8
+	github.com/robfig/cron/v3 v3.0.1
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
+	golang.org/x/oauth2 v0.26.0
+	golang.org/x/time v0.10.0
+)
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const metricHealth = "health"
+
+type healthOptions struct {
+	weights map[string]float64
+	floor   float64 // 0 — алерт по здоровью выключен
+}
+
+func defaultHealthWeights() map[string]float64 {
+	return map[string]float64{metricLoad: 1, metricMem: 1, metricDisk: 1, metricNet: 1}
+}
+
+// usage — загрузка метрики в [0, 1] по текущим настройкам проверок: load
+// нормируется на действующий порог алерта (в LOAD_MODE=percent — на 100),
+// память считается по MEM_FORMULA, как в алерте.
+// ok == false, если данных нет (нулевой объём).
+func (s Stats) usage(metric string, opts checkOptions) (u float64, ok bool) {
+	frac := func(used, total uint64) (float64, bool) {
+		if total == 0 {
+			return 0, false
+		}
+		return float64(used) / float64(total), true
+	}
+	switch metric {
+	case metricLoad:
+		u, ok = s.LoadAvg/opts.crit.load, true
+		if s.LoadPercent {
+			u = s.LoadAvg / 100
+		}
+	case metricMem:
+		u, ok = frac(s.memUsed(opts.memFormula), s.TotalRAM)
+	case metricDisk:
+		u, ok = frac(s.UsedDisk, s.TotalDisk)
+	case metricNet:
+		u, ok = frac(s.NetUsed, s.NetCapacity)
+	}
+	return math.Min(math.Max(u, 0), 1), ok
+}
+
+// healthScore = 100 · Σ wᵢ·(1 − uᵢ) / Σ wᵢ, где uᵢ — usage метрики.
+// Веса из HEALTH_WEIGHTS нормируются на сумму; метрики без данных не
+// учитываются. Load — относительно текущего порога opts.crit.load.
+func healthScore(s Stats, opts checkOptions) (float64, bool) {
+	var sum, total float64
+	for metric, w := range opts.health.weights {
+		u, ok := s.usage(metric, opts)
+		if !ok || w == 0 {
+			continue
+		}
+		sum += w * (1 - u)
+		total += w
+	}
+	if total == 0 {
+		return 0, false
+	}
+	return 100 * sum / total, true
+}
+
+func formatWeights(weights map[string]float64) string {
+	var parts []string
+	for _, metric := range allMetrics {
+		if w, ok := weights[metric]; ok {
+			parts = append(parts, metric+"="+strconv.FormatFloat(w, 'g', -1, 64))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseWeights разбирает "load=2,mem=1,disk=1,net=0.5".
+// Неуказанные метрики получают вес 1.
+func parseWeights(v string) (map[string]float64, error) {
+	weights := defaultHealthWeights()
+	if v == "" {
+		return weights, nil
+	}
+	for _, pair := range strings.Split(v, ",") {
+		name, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("expected metric=weight, got %q", pair)
+		}
+		if _, known := weights[name]; !known {
+			return nil, fmt.Errorf("unknown metric %q", name)
+		}
+		w, err := strconv.ParseFloat(val, 64)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid weight for %s: %q", name, val)
+		}
+		weights[name] = w
+	}
+	return weights, nil
+}
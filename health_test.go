@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHealthScoreExtremes(t *testing.T) {
+	opts := checkOptions{crit: defaultCrit(), health: healthOptions{weights: defaultHealthWeights()}}
+	cases := []struct {
+		name   string
+		s      Stats
+		want   float64
+		wantOK bool
+	}{
+		{"all free", Stats{LoadAvg: 0, TotalRAM: 100, TotalDisk: 100, NetCapacity: 100}, 100, true},
+		{"all saturated", Stats{LoadAvg: 30, TotalRAM: 100, UsedRAM: 100, TotalDisk: 100, UsedDisk: 100, NetCapacity: 100, NetUsed: 100}, 0, true},
+		{"over limits clamps to 0", Stats{LoadAvg: 90, TotalRAM: 100, UsedRAM: 100, TotalDisk: 100, UsedDisk: 100, NetCapacity: 100, NetUsed: 100}, 0, true},
+		// Нулевой объём не учитывается: остаются load (0.5) и память (0)
+		{"zero totals skipped", Stats{LoadAvg: 15, TotalRAM: 100, UsedRAM: 100}, 25, true},
+		{"only load", Stats{LoadAvg: 0}, 100, true},
+	}
+	for _, c := range cases {
+		got, ok := healthScore(c.s, opts)
+		if got != c.want || ok != c.wantOK {
+			t.Errorf("%s: score = %v, %v; want %v, %v", c.name, got, ok, c.want, c.wantOK)
+		}
+	}
+
+	// Без load в весах и без объёмов считать не из чего
+	opts.health.weights = map[string]float64{metricLoad: 0, metricMem: 1, metricDisk: 1, metricNet: 1}
+	if got, ok := healthScore(Stats{LoadAvg: 10}, opts); ok {
+		t.Errorf("no data: score = %v, want ok == false", got)
+	}
+}
+
+// Память в оценке — по MEM_FORMULA, как в алерте: used 90 из 100, но
+// mem_available 60 — по auto занято 40.
+func TestHealthScoreFollowsMemFormula(t *testing.T) {
+	s := Stats{TotalRAM: 100, UsedRAM: 90, Extra: map[string]float64{extraMemAvailable: 60}}
+	opts := checkOptions{crit: defaultCrit(), health: healthOptions{weights: map[string]float64{metricMem: 1}}}
+	for formula, want := range map[string]float64{memFormulaAuto: 60, memFormulaUsed: 10} {
+		opts.memFormula = formula
+		if got, _ := healthScore(s, opts); math.Abs(got-want) > 1e-9 {
+			t.Errorf("MEM_FORMULA=%s: score = %v, want %v", formula, got, want)
+		}
+	}
+}
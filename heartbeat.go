@@ -0,0 +1,32 @@
+package main
+
+import "time"
+
+const metricHeartbeat = "heartbeat"
+
+// heartbeat раз в HEARTBEAT_INTERVAL сообщает получателям, что мониторинг
+// жив и все метрики в норме. Пока хоть одна метрика нарушена, молчит;
+// отсчёт идёт от последнего сообщения или от старта.
+type heartbeat struct {
+	interval time.Duration // 0 — выключено
+	last     time.Time
+}
+
+func (h *heartbeat) due(now time.Time, breached bool) (Alert, bool) {
+	if h.interval <= 0 {
+		return Alert{}, false
+	}
+	if h.last.IsZero() {
+		h.last = now
+	}
+	if breached || now.Sub(h.last) < h.interval {
+		return Alert{}, false
+	}
+	h.last = now
+	return Alert{
+		Metric:  metricHeartbeat,
+		Status:  statusOK,
+		Message: "All systems normal.",
+		Time:    now,
+	}, true
+}
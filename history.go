@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultHistorySize = 300
+
+type sample struct {
+	At      time.Time
+	Latency time.Duration // сеть: запрос и чтение тела
+	Parse   time.Duration // разбор тела; обычно микросекунды
+	Stats   Stats
+	Derived Derived
+}
+
+// history — кольцевой буфер последних успешных опросов.
+type history struct {
+	mu   sync.Mutex
+	buf  []sample
+	next int
+	n    int
+}
+
+func newHistory(size int) *history {
+	return &history{buf: make([]sample, size)}
+}
+
+func (h *history) add(s sample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.buf[h.next] = s
+	h.next = (h.next + 1) % len(h.buf)
+	if h.n < len(h.buf) {
+		h.n++
+	}
+}
+
+// samples возвращает копию буфера от старых к новым.
+func (h *history) samples() []sample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]sample, 0, h.n)
+	start := (h.next - h.n + len(h.buf)) % len(h.buf)
+	for i := 0; i < h.n; i++ {
+		out = append(out, h.buf[(start+i)%len(h.buf)])
+	}
+	return out
+}
+
+// recent возвращает до k последних снимков от старых к новым.
+func (h *history) recent(k int) []sample {
+	ss := h.samples()
+	if len(ss) > k {
+		ss = ss[len(ss)-k:]
+	}
+	return ss
+}
+
+type percentiles struct {
+	Samples int     `json:"samples"`
+	P50     float64 `json:"p50"`
+	P95     float64 `json:"p95"`
+	P99     float64 `json:"p99"`
+}
+
+// String — "load p50 1.5, p95 2.8, p99 3.1 over 120 samples" для сводок.
+func (p percentiles) String() string {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	return fmt.Sprintf("load p50 %s, p95 %s, p99 %s over %d samples", f(p.P50), f(p.P95), f(p.P99), p.Samples)
+}
+
+// loadPercentiles считает перцентили load average по окну буфера
+// (nearest-rank). При пустом буфере ok == false.
+func (h *history) loadPercentiles() (p percentiles, ok bool) {
+	ss := h.samples()
+	if len(ss) == 0 {
+		return p, false
+	}
+	loads := make([]float64, len(ss))
+	for i, s := range ss {
+		loads[i] = s.Stats.LoadAvg
+	}
+	sort.Float64s(loads)
+	rank := func(q float64) float64 {
+		i := int(math.Ceil(q*float64(len(loads)))) - 1
+		return loads[max(i, 0)]
+	}
+	return percentiles{Samples: len(loads), P50: rank(0.50), P95: rank(0.95), P99: rank(0.99)}, true
+}
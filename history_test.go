@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestLoadPercentilesPartialBuffer(t *testing.T) {
+	h := newHistory(10)
+	if _, ok := h.loadPercentiles(); ok {
+		t.Fatal("empty buffer gave percentiles")
+	}
+	for _, load := range []float64{4, 1, 3, 2} {
+		h.add(sample{Stats: Stats{LoadAvg: load}})
+	}
+	p, ok := h.loadPercentiles()
+	if !ok {
+		t.Fatal("no percentiles for 4 samples")
+	}
+	if want := "load p50 2, p95 4, p99 4 over 4 samples"; p.String() != want {
+		t.Errorf("percentiles = %q, want %q", p, want)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Плейсхолдеры шаблона STATS_URL
+const (
+	placeholderHost   = "{host}"
+	placeholderRegion = "{region}"
+)
+
+// expandURL подставляет хост и регион в шаблон адреса.
+func expandURL(tmpl, host, region string) string {
+	return strings.NewReplacer(placeholderHost, host, placeholderRegion, region).Replace(tmpl)
+}
+
+func (c config) validateHosts() error {
+	templated := strings.Contains(c.request.url, placeholderHost)
+	switch {
+	case templated && len(c.hosts) == 0:
+		return fmt.Errorf("STATS_URL contains %s but HOSTS is empty", placeholderHost)
+	case !templated && len(c.hosts) > 0:
+		return fmt.Errorf("HOSTS requires %s in STATS_URL", placeholderHost)
+	case strings.Contains(c.request.url, placeholderRegion) && c.region == "":
+		return fmt.Errorf("STATS_URL contains %s but REGION is empty", placeholderRegion)
+	case len(c.hosts) > 0 && c.adminAddr != "":
+		return errors.New("ADMIN_ADDR is not supported with HOSTS yet")
+	}
+	seen := make(map[string]bool, len(c.hosts))
+	for _, host := range c.hosts {
+		if seen[host] {
+			return fmt.Errorf("HOSTS: duplicate host %q", host)
+		}
+		seen[host] = true
+		if err := validateURL(expandURL(c.request.url, host, c.region)); err != nil {
+			return fmt.Errorf("STATS_URL for host %s: %w", host, err)
+		}
+	}
+	return nil
+}
+
+// servers раскладывает конфиг по серверам: без HOSTS — он сам, с HOSTS —
+// копия на каждый хост со своим адресом и меткой (имя хоста из HOSTS).
+// К адресам дописываются параметры STATS_QUERY.
+func (c config) servers() []config {
+	if len(c.hosts) == 0 {
+		return []config{c.withQuery()}
+	}
+	out := make([]config, len(c.hosts))
+	for i, host := range c.hosts {
+		sc := c
+		sc.request.url = expandURL(c.request.url, host, c.region)
+		if c.fallbackURL != "" {
+			sc.fallbackURL = expandURL(c.fallbackURL, host, c.region)
+		}
+		sc.serverLabel = host
+		out[i] = sc.withQuery()
+	}
+	return out
+}
+
+// withQuery: неразбираемый адрес остаётся как есть — его отсеет validate.
+func (c config) withQuery() config {
+	if u, err := mergeQuery(c.request.url, c.statsQuery); err == nil && c.request.url != stdinURL {
+		c.request.url = u
+	}
+	if u, err := mergeQuery(c.fallbackURL, c.statsQuery); err == nil && c.fallbackURL != "" {
+		c.fallbackURL = u
+	}
+	return c
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"maps"
+	"math"
+	"sync"
+	"time"
+)
+
+type metricsRecord struct {
+	Time          time.Time          `json:"time"`
+	Record        string             `json:"record,omitempty"`
+	CorrelationID string             `json:"correlation_id,omitempty"`
+	LatencyMS     float64            `json:"latency_ms"` // сеть
+	ParseMS       float64            `json:"parse_ms"`
+	Stats         Stats              `json:"stats"`
+	Ratios        map[string]float64 `json:"ratios"`
+	Derived       Derived            `json:"derived"`
+}
+
+// jsonlLog дописывает по строке JSON на каждый успешный опрос. Запись идёт
+// прямо в файл без буфера, так что при падении процесса хвост не теряется.
+//
+// С minDelta > 0 строка пишется, только если какая-то доля (или load,
+// нормированный на порог) сдвинулась больше чем на minDelta с последней
+// записанной строки той же записи того же сервера, и не реже раза в maxGap.
+// Лог общий для всех серверов HOSTS.
+type jsonlLog struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+
+	minDelta float64
+	maxGap   time.Duration
+	last     map[jsonlKey]lastWritten
+}
+
+// jsonlKey — сервер и метка записи; "" — ответ без меток.
+type jsonlKey struct {
+	server, record string
+}
+
+// lastWritten — доли последней записанной строки и её время.
+type lastWritten struct {
+	ratios map[string]float64
+	at     time.Time
+}
+
+func newJSONLLog(w io.Writer, minDelta float64, maxGap time.Duration) *jsonlLog {
+	return &jsonlLog{w: w, enc: json.NewEncoder(w), minDelta: minDelta, maxGap: maxGap, last: make(map[jsonlKey]lastWritten)}
+}
+
+// changed решает, писать ли строку записи key; вызывается под mu.
+func (l *jsonlLog) changed(key jsonlKey, cur map[string]float64, at time.Time) bool {
+	last, ok := l.last[key]
+	if l.minDelta <= 0 || !ok || at.Sub(last.at) >= l.maxGap {
+		return true
+	}
+	for metric, v := range cur {
+		prev, ok := last.ratios[metric]
+		if !ok || math.Abs(v-prev) > l.minDelta {
+			return true
+		}
+	}
+	return len(cur) != len(last.ratios)
+}
+
+func (l *jsonlLog) write(smp sample, server, label, correlationID string, opts checkOptions) error {
+	rec := metricsRecord{
+		Time:          smp.At.UTC(),
+		Record:        label,
+		CorrelationID: correlationID,
+		LatencyMS:     float64(smp.Latency.Microseconds()) / 1000,
+		ParseMS:       float64(smp.Parse.Nanoseconds()) / 1e6,
+		Stats:         smp.Stats,
+		Ratios:        make(map[string]float64, 3),
+		Derived:       smp.Derived,
+	}
+	for _, metric := range []string{metricMem, metricDisk, metricNet} {
+		if u, ok := smp.Stats.usage(metric, opts); ok {
+			rec.Ratios[metric] = u
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cur := maps.Clone(rec.Ratios)
+	cur[metricLoad], _ = smp.Stats.usage(metricLoad, opts)
+	key := jsonlKey{server: server, record: label}
+	if !l.changed(key, cur, smp.At) {
+		return nil
+	}
+	l.last[key] = lastWritten{ratios: cur, at: smp.At}
+	return l.enc.Encode(rec)
+}
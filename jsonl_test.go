@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Записи многострочного ответа сравниваются каждая со своей прошлой
+// строкой: две ровные записи дают по строке, сколько бы ни было опросов.
+func TestJSONLMinDeltaPerRecord(t *testing.T) {
+	var buf bytes.Buffer
+	l := newJSONLLog(&buf, 0.05, time.Hour)
+	web1 := Stats{LoadAvg: 1, TotalRAM: 100, UsedRAM: 10}
+	web2 := Stats{LoadAvg: 1, TotalRAM: 100, UsedRAM: 90}
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 10 {
+		now := at.Add(time.Duration(i) * time.Second)
+		for _, r := range []struct {
+			label string
+			s     Stats
+		}{{"web1", web1}, {"web2", web2}} {
+			if err := l.write(sample{At: now, Stats: r.s}, "srv", r.label, "", checkOptions{crit: defaultCrit()}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 2 {
+		t.Errorf("wrote %d lines, want 2", n)
+	}
+}
+
+// То же для серверов HOSTS, пишущих в общий лог.
+func TestJSONLMinDeltaPerServer(t *testing.T) {
+	var buf bytes.Buffer
+	l := newJSONLLog(&buf, 0.05, time.Hour)
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 10 {
+		now := at.Add(time.Duration(i) * time.Second)
+		for server, used := range map[string]uint64{"web1": 10, "web2": 90} {
+			if err := l.write(sample{At: now, Stats: Stats{LoadAvg: 1, TotalRAM: 100, UsedRAM: used}}, server, "", "", checkOptions{crit: defaultCrit()}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 2 {
+		t.Errorf("wrote %d lines, want 2", n)
+	}
+}
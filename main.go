@@ -1,144 +1,280 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
-	"strconv"
-	"strings"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 )
 
 const (
 	statsURL = "http://srv.msk01.gigacorp.local/_stats"
 
-	// Пороговые условия
-	loadAvgThreshold  = 30.0
-	memUsageThreshold = 80 // в процентах
-	diskUsageLimit    = 90 // в процентах
-	netUsageLimit     = 90 // в процентах
+	timeFormat = time.RFC3339
+)
 
-	oneMiB = 1024 * 1024
+// Коды выхода
+const (
+	exitOK       = 0 // порогов не нарушено или штатное завершение
+	exitAlert    = 1 // -once -fail-on-alert: нарушен хотя бы один порог
+	exitSelftest = 1 // -selftest: хотя бы одна фикстура не прошла
+	exitError    = 2 // ошибка конфигурации, получения или разбора статистики
+	exitCritHeld = 3 // CRIT_EXIT_METRIC в CRIT дольше CRIT_EXIT_AFTER
 )
 
-func getenvInt(name string, def int) int {
-	if v := os.Getenv(name); v != "" {
-		if n, err := strconv.Atoi(v); err == nil && n > 0 {
-			return n
-		}
+var (
+	onceFlag        = flag.Bool("once", false, "poll once, report alerts and exit")
+	failOnAlertFlag = flag.Bool("fail-on-alert", false, "with -once: exit 1 if any threshold is breached")
+	stdinFlag       = flag.Bool("stdin", false, "read a single CSV line from stdin, check it and exit")
+	selftestFlag    = flag.Bool("selftest", false, "check parsing and thresholds against built-in fixtures and exit")
+	checkFlag       = flag.Bool("check", false, "poll once and print a Nagios/Icinga plugin status line; exit 0/1/2/3")
+	requireInitFlag = flag.Bool("require-initial-success", false, "exit 2 if the first poll fails instead of starting the loop")
+	parseOnlyFlag   = flag.Bool("parse-only", false, "fetch or read one response, print the parsed stats without threshold checks and exit")
+	formatFlag      = flag.String("format", formatJSON, "output format: json or csv for -parse-only, cef for alerts")
+	probeFlag       = flag.Bool("probe", false, "query /health of a running instance (address as argument or ADMIN_ADDR) and exit 0 if healthy, 1 otherwise")
+	maxRuntimeFlag  = flag.Duration("max-runtime", 0, "stop after this long and print a run summary (0 = run forever)")
+	explainFlag     = flag.Bool("explain", false, "append the computation behind each threshold alert to text output")
+	defaultsFlag    = flag.Bool("defaults", false, "print the built-in default of every setting as KEY=value lines and exit")
+	replayFlag      = flag.String("replay", "", "run the checks over a METRICS_JSONL file with its recorded times, print the alerts and exit")
+)
+
+// Значения WARN_STREAM и CRIT_STREAM
+const (
+	streamStdout = "stdout" // основной вывод: stdout или LOG_FILE
+	streamStderr = "stderr"
+)
+
+// alertStream: nil — основной вывод монитора.
+func alertStream(name string) io.Writer {
+	if name == streamStderr {
+		return os.Stderr
 	}
-	return def
+	return nil
 }
 
 func main() {
-	interval := time.Duration(getenvInt("POLL_INTERVAL_MS", 200)) * time.Millisecond
-	client := &http.Client{Timeout: 1500 * time.Millisecond}
+	flag.Parse()
+	os.Exit(run())
+}
 
-	consecutiveErrors := 0
-	errorPrinted := false
+func run() int {
+	if *selftestFlag {
+		return runSelftest(os.Stdout)
+	}
+	if *defaultsFlag {
+		return runDefaults(os.Stdout)
+	}
+	// Конфиг не читается: пробе нужен только адрес админки
+	if *probeFlag {
+		return runProbe(flag.Arg(0), os.Stdout, os.Stderr)
+	}
 
-	for {
-		err := pollOnce(client)
-		if err != nil {
-			consecutiveErrors++
-			if consecutiveErrors >= 3 && !errorPrinted {
-				fmt.Println("Unable to fetch server statistic.")
-				errorPrinted = true
-			}
-		} else {
-			consecutiveErrors = 0
-			errorPrinted = false
+	cfg, err := loadConfig()
+	if err != nil {
+		if *checkFlag {
+			fmt.Printf("%s: config: %v\n", nagiosStatus[nagiosUnknown], err)
+			return nagiosUnknown
 		}
-		time.Sleep(interval)
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		return exitError
 	}
-}
 
-func pollOnce(client *http.Client) error {
-	req, err := http.NewRequest(http.MethodGet, statsURL, nil)
-	if err != nil {
-		return err
+	// stdin читается один раз, поэтому это всегда разовый запуск
+	fromStdin := *stdinFlag || cfg.request.url == stdinURL
+	once := *onceFlag || fromStdin
+	if *failOnAlertFlag && !once {
+		fmt.Fprintln(os.Stderr, "-fail-on-alert requires -once")
+		return exitError
 	}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
+	out := io.Writer(os.Stdout)
+	if cfg.logFile != "" {
+		f, err := openRotatingFile(cfg.logFile, cfg.logMaxMB, cfg.logMaxBackups)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "open log file: %v\n", err)
+			return exitError
+		}
+		defer f.Close()
+		out = f
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad status: %s", resp.Status)
+	var jsonl *jsonlLog
+	if cfg.metricsJSONL != "" {
+		f, err := openRotatingFile(cfg.metricsJSONL, cfg.logMaxMB, cfg.logMaxBackups)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "open metrics log: %v\n", err)
+			return exitError
+		}
+		defer f.Close()
+		jsonl = newJSONLLog(f, cfg.minDelta, cfg.minDeltaMaxGap)
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("read body: %w", err)
+	// -format по умолчанию json — это про -parse-only; для алертов есть только cef
+	alertFmt := ""
+	if *formatFlag == formatCEF {
+		alertFmt = formatCEF
 	}
-	line := strings.TrimSpace(string(body))
-	if line == "" {
-		return errors.New("empty body")
+	if !*parseOnlyFlag && *formatFlag != formatJSON && *formatFlag != formatCEF {
+		fmt.Fprintf(os.Stderr, "-format must be %q (%q and %q are for -parse-only)\n", formatCEF, formatJSON, formatCSV)
+		return exitError
 	}
-
-	fields := strings.Split(line, ",")
-	if len(fields) != 7 {
-		return fmt.Errorf("unexpected fields count: %d", len(fields))
+	if *replayFlag != "" && (*onceFlag || *stdinFlag || *checkFlag || *parseOnlyFlag) {
+		fmt.Fprintln(os.Stderr, "-replay cannot be combined with -once, -stdin, -check or -parse-only")
+		return exitError
+	}
+	if alertFmt != "" && (*parseOnlyFlag || *checkFlag) {
+		fmt.Fprintln(os.Stderr, "-format cef cannot be combined with -parse-only or -check")
+		return exitError
 	}
 
-	// 0: load avg
-	loadAvg, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
-	if err != nil {
-		return fmt.Errorf("parse load avg: %w", err)
+	servers := cfg.servers()
+	if len(servers) > 1 && *checkFlag {
+		fmt.Println(nagiosStatus[nagiosUnknown] + ": -check supports a single server, HOSTS is set")
+		return nagiosUnknown
+	}
+	outMu := new(sync.Mutex)
+	pool := newPollPool(cfg.maxConcurrency)
+	var fleet *fleetDedup
+	monitors := make([]*monitor, len(servers))
+	for i, sc := range servers {
+		m := newMonitor(sc, out, outMu, jsonl)
+		m.pool = pool
+		if cfg.fleetDedup {
+			if fleet == nil {
+				fleet = newFleetDedup(cfg.fleetDedupWindow, m.printf)
+			}
+			m.fleet = fleet
+		}
+		m.warnOut, m.critOut = alertStream(sc.warnStream), alertStream(sc.critStream)
+		m.alertFmt = alertFmt
+		m.explain = *explainFlag
+		if len(servers) > 1 {
+			m.prefix = "[" + sc.serverLabel + "] "
+		}
+		if fromStdin {
+			m.fetch = func() (string, error) { return readLine(os.Stdin) }
+		}
+		if err := m.buildSinks(sc.notifiers); err != nil {
+			fmt.Fprintf(os.Stderr, "notifiers: %v\n", err)
+			return exitError
+		}
+		monitors[i] = m
 	}
-	// 1–6: остальные показатели
-	totalRAM, _ := strconv.ParseUint(strings.TrimSpace(fields[1]), 10, 64)
-	usedRAM, _ := strconv.ParseUint(strings.TrimSpace(fields[2]), 10, 64)
-	totalDisk, _ := strconv.ParseUint(strings.TrimSpace(fields[3]), 10, 64)
-	usedDisk, _ := strconv.ParseUint(strings.TrimSpace(fields[4]), 10, 64)
-	netCap, _ := strconv.ParseUint(strings.TrimSpace(fields[5]), 10, 64)
-	netUsed, _ := strconv.ParseUint(strings.TrimSpace(fields[6]), 10, 64)
 
-	// 1) Load Average
-	if loadAvg > loadAvgThreshold {
-		fmt.Printf("Load Average is too high: %s\n", trimTrailingZeros(fields[0]))
+	if *checkFlag {
+		return monitors[0].runCheck()
+	}
+	if *replayFlag != "" {
+		return monitors[0].runReplay(*replayFlag)
+	}
+	if *parseOnlyFlag {
+		if err := validParseFormat(*formatFlag); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		code := exitOK
+		for _, m := range monitors {
+			code = max(code, m.runParseOnly(*formatFlag, os.Stdout, os.Stderr))
+		}
+		return code
+	}
+	if once {
+		code := exitOK
+		for _, m := range monitors {
+			code = max(code, m.runOnce(*failOnAlertFlag))
+			m.drainSinks()
+		}
+		return code
 	}
 
-	// 2) Память
-	if totalRAM > 0 {
-		percent := int((usedRAM * 100) / totalRAM) // без округления
-		if percent > memUsageThreshold {
-			fmt.Printf("Memory usage too high: %d%%\n", percent)
+	if cfg.stateFile != "" {
+		saved, err := loadState(cfg.stateFile)
+		if err != nil {
+			fmt.Fprintf(out, "State file %s is unreadable, starting fresh: %v\n", cfg.stateFile, err)
+			saved = savedState{}
+		}
+		store := newStateStore(cfg.stateFile, cfg.stateSaveInterval, saved)
+		for _, m := range monitors {
+			m.stateStore = store
+			m.restoreState(saved)
 		}
+		defer func() {
+			if err := store.save(time.Now()); err != nil {
+				fmt.Fprintf(os.Stderr, "save state: %v\n", err)
+			}
+		}()
 	}
 
-	// 3) Диск
-	if totalDisk > 0 {
-		percent := int((usedDisk * 100) / totalDisk)
-		if percent > diskUsageLimit {
-			freeMB := (totalDisk - usedDisk) / oneMiB
-			fmt.Printf("Free disk space is too low: %d Mb left\n", freeMB)
+	if !fromStdin {
+		var wg sync.WaitGroup
+		for _, m := range monitors {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				m.warmupConnection(context.Background())
+			}()
 		}
+		wg.Wait()
 	}
 
-	// 4) Сеть
-	if netCap > 0 {
-		percent := int((netUsed * 100) / netCap)
-		if percent > netUsageLimit {
-			freeBytes := netCap - netUsed
-			// Тесты ожидают деление на 1_000_000, а не на 1024*1024 и без *8
-			freeMbit := int(freeBytes / 1_000_000)
-			fmt.Printf("Network bandwidth usage high: %d Mbit/s available\n", freeMbit)
+	// Проверка до сигналов и админки: при ошибке процесс не успевает «подняться»
+	if *requireInitFlag {
+		for _, m := range monitors {
+			if _, err := m.pollOnce(); err != nil && !errors.Is(err, errNoData) {
+				fmt.Fprintf(os.Stderr, "%sinitial poll failed: %v\n", m.prefix, err)
+				return exitError
+			}
 		}
 	}
 
-	return nil
-}
+	for _, m := range monitors {
+		m.snooze = newSnooze(cfg.snoozeDuration, m.printf)
+		handleSignals(m)
+	}
+	monitors[0].startAdmin(cfg.adminAddr) // с HOSTS ADMIN_ADDR запрещён
+	monitors[0].startPprof(cfg.pprofAddr)
 
-func trimTrailingZeros(s string) string {
-	if !strings.Contains(s, ".") {
-		return s
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *maxRuntimeFlag > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *maxRuntimeFlag)
+		defer cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	started := time.Now()
+	var wg sync.WaitGroup
+	for _, m := range monitors {
+		m.stop = cancel
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.run(ctx, cfg.interval)
+		}()
+	}
+	wg.Wait()
+	for _, m := range monitors {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			msg := m.counts.summary()
+			if p, ok := m.hist.loadPercentiles(); ok {
+				msg += "; " + p.String()
+			}
+			m.printf("Run finished after %s: %s.", formatDuration(time.Since(started)), msg)
+		}
+		m.reportShutdown(time.Now())
+		m.unmarkReady()
+	}
+	for _, m := range monitors {
+		if m.critHeld.Load() {
+			return exitCritHeld
+		}
 	}
-	s = strings.TrimRight(s, "0")
-	s = strings.TrimRight(s, ".")
-	return s
+	return exitOK
 }
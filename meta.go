@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"strconv"
+)
+
+// alertMeta — сведения о самом мониторе для INCLUDE_META: отличают алерты
+// нескольких экземпляров, следящих за одним сервером.
+type alertMeta struct {
+	host        string
+	pid         int
+	environment string // ENVIRONMENT: prod, staging...
+}
+
+// newAlertMeta собирает сведения один раз при запуске.
+func newAlertMeta(environment string) *alertMeta {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return &alertMeta{host: host, pid: os.Getpid(), environment: environment}
+}
+
+// metaPrefix — префикс текстового алерта: "[mon1 pid=4242 prod] ".
+// Пусто, если алерт без сведений о мониторе.
+func metaPrefix(a Alert) string {
+	if a.MonitorHost == "" {
+		return ""
+	}
+	p := "[" + a.MonitorHost + " pid=" + strconv.Itoa(a.MonitorPID)
+	if a.Environment != "" {
+		p += " " + a.Environment
+	}
+	return p + "] "
+}
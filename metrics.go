@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// metrics — счётчики алертов для /metrics в текстовом формате Prometheus.
+type metrics struct {
+	mu       sync.Mutex
+	alerts   map[string]uint64
+	failures map[string]uint64 // по получателю: доставка не удалась после всех повторов
+	tokens   uint64            // неудачных получений токена OAuth2
+}
+
+func newMetrics() *metrics {
+	return &metrics{alerts: make(map[string]uint64), failures: make(map[string]uint64)}
+}
+
+func (p *metrics) tokenFailed() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tokens++
+}
+
+func (p *metrics) notifierFailed(notifier string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failures[notifier]++
+}
+
+func (p *metrics) Notify(a Alert) error {
+	if a.Status != statusFiring {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.alerts[a.Metric]++
+	return nil
+}
+
+func (m *monitor) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.writeMetrics(w, time.Now())
+}
+
+// writeMetrics — счётчики и показатели последнего снимка; их же
+// получает Pushgateway.
+func (m *monitor) writeMetrics(w io.Writer, now time.Time) {
+	m.metrics.write(w)
+	rate, _ := m.errRate.rate(now)
+	gauge(w, "monitor_poll_error_rate", rate)
+	// Пул общий для всех серверов: в HOSTS его видно через Pushgateway
+	if p := m.pool.stats(); p != nil {
+		gauge(w, "monitor_poll_pool_size", float64(p.Size))
+		gauge(w, "monitor_poll_pool_active", float64(p.Active))
+		gauge(w, "monitor_poll_pool_waiting", float64(p.Waiting))
+	}
+
+	ss := m.hist.samples()
+	if len(ss) == 0 {
+		return
+	}
+	last := ss[len(ss)-1]
+	s := last.Stats
+	gauge(w, "server_stats_age_seconds", now.Sub(last.At).Seconds())
+	gauge(w, "server_stats_fetch_seconds", last.Latency.Seconds())
+	gauge(w, "server_stats_parse_seconds", last.Parse.Seconds())
+	gauge(w, "server_load_avg", s.LoadAvg)
+	gauge(w, "server_ram_total_bytes", float64(s.TotalRAM))
+	gauge(w, "server_ram_used_bytes", float64(s.UsedRAM))
+	gauge(w, "server_disk_total_bytes", float64(s.TotalDisk))
+	gauge(w, "server_disk_used_bytes", float64(s.UsedDisk))
+	gauge(w, "server_net_capacity", float64(s.NetCapacity))
+	gauge(w, "server_net_used", float64(s.NetUsed))
+	if t, ok := s.Extra[extraTemp]; ok {
+		gauge(w, "server_cpu_temp_celsius", t)
+	}
+	if v, ok := s.Extra[extraSteal]; ok {
+		gauge(w, "server_cpu_steal_percent", v)
+	}
+	if v, ok := s.Extra[extraIOWait]; ok {
+		gauge(w, "server_cpu_iowait_percent", v)
+	}
+	if score, ok := healthScore(s, m.checkOptions()); ok {
+		gauge(w, "server_health_score", score)
+	}
+	if age, ok := dataAge(s, now); ok {
+		gauge(w, "server_data_age_seconds", age.Seconds())
+	}
+}
+
+func (p *metrics) write(w io.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	names := make([]string, 0, len(p.alerts))
+	for name := range p.alerts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "# TYPE monitor_alerts_total counter")
+	for _, name := range names {
+		fmt.Fprintf(w, "monitor_alerts_total{metric=%q} %d\n", name, p.alerts[name])
+	}
+	if p.tokens > 0 {
+		fmt.Fprintf(w, "# TYPE token_refresh_failures_total counter\ntoken_refresh_failures_total %d\n", p.tokens)
+	}
+	if len(p.failures) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "# TYPE notifier_failures_total counter")
+	notifiers := make([]string, 0, len(p.failures))
+	for name := range p.failures {
+		notifiers = append(notifiers, name)
+	}
+	sort.Strings(notifiers)
+	for _, name := range notifiers {
+		fmt.Fprintf(w, "notifier_failures_total{notifier=%q} %d\n", name, p.failures[name])
+	}
+}
+
+func gauge(w io.Writer, name string, v float64) {
+	fmt.Fprintf(w, "# TYPE %s gauge\n%s %g\n", name, name, v)
+}
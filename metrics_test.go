@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteMetricsPollPool(t *testing.T) {
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newMonitor(cfg, io.Discard, new(sync.Mutex), nil)
+	var b strings.Builder
+	m.writeMetrics(&b, time.Now())
+	if strings.Contains(b.String(), "monitor_poll_pool") {
+		t.Errorf("pool gauges without MAX_CONCURRENCY:\n%s", b.String())
+	}
+
+	m.pool = newPollPool(3)
+	release, err := m.pool.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	b.Reset()
+	m.writeMetrics(&b, time.Now())
+	for _, want := range []string{"monitor_poll_pool_size 3\n", "monitor_poll_pool_active 1\n", "monitor_poll_pool_waiting 0\n"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("metrics lack %q:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+type monitor struct {
+	ctx    context.Context // отменяется при завершении; прерывает текущий опрос
+	stop   func()          // завершает все мониторы процесса (CRIT_EXIT_AFTER)
+	cfg    config
+	cfgMu  sync.RWMutex // cfg.check меняется из POST /config и по SIGHUP на ходу
+	client *http.Client
+	auth   *tokenAuth // nil — TOKEN_URL не задан
+	// fetch — опрос целиком, с повторами; -stdin читает строку.
+	// fetcher — одна попытка запроса: основной адрес, затем запасной.
+	fetch   func() (string, error)
+	fetcher StatsFetcher
+	clock   Clock
+	rand    *rand.Rand // разброс пауз между повторами, см. RAND_SEED
+	out     io.Writer
+	// Потоки алертов по уровню; nil — out
+	warnOut, critOut io.Writer
+	snooze           *snooze
+	hist             *history
+	state            *tracker
+	stale            staleness
+	errRate          *errorRate
+	budget           *retryBudget
+
+	// limiter — общий потолок частоты запросов статистики: через него проходят
+	// плановые, внеочередные, повторные и запасные запросы
+	limiter *rate.Limiter
+	pool    *pollPool   // MAX_CONCURRENCY, общий для всех серверов
+	fleet   *fleetDedup // FLEET_DEDUP, общий для всех серверов; nil — выключен
+
+	sinks   []sink
+	metrics *metrics
+	jsonl   *jsonlLog // nil — METRICS_JSONL не задан
+	pusher  *pusher   // nil — PUSHGATEWAY_URL не задан
+	// stateStore — STATE_FILE, общий для всех серверов; nil — не задан
+	stateStore *stateStore
+	acks       *acks
+	ewma       ewma
+	swap       swapDetector
+
+	capacity capacityWatch
+	flap     *flapDetector
+
+	heartbeat heartbeat
+	server    string // метка сервера в алертах
+	alertFmt  string // -format cef; "" — текст
+	explain   bool   // -explain: расчёт к текстовым алертам
+
+	recordStates map[string]*tracker // по метке записи многострочного ответа
+	lastRecs     []record            // последний успешный опрос, для сводки при завершении
+	counts       runCounts
+
+	// trigger запрашивает внеочередной опрос; буфер 1 схлопывает повторные запросы
+	trigger chan struct{}
+
+	mu     sync.Mutex  // сериализует запись в out из цикла и обработчиков сигналов
+	outMu  *sync.Mutex // то же между мониторами разных серверов
+	pollID string      // идентификатор текущего опроса; пишется под mu
+	prefix string      // "[host] " в режиме HOSTS
+
+	consecutiveErrors atomic.Int64 // читается и из /health
+	errorPrinted      bool
+	okStreak          int // успешных опросов подряд
+	extraFieldsLogged bool
+	warmupLeft        int
+	ready             atomic.Bool
+	critHeld          atomic.Bool // завершились по CRIT_EXIT_AFTER
+
+	httpsHinted    atomic.Bool // подсказка о переезде на https уже напечатана
+	preferFallback bool
+	lastServedBy   string
+}
+
+// newMonitor собирает монитор одного сервера. out и outMu общие для всех
+// мониторов процесса, чтобы строки разных серверов не перемешивались.
+func newMonitor(cfg config, out io.Writer, outMu *sync.Mutex, jsonl *jsonlLog) *monitor {
+	m := &monitor{
+		ctx:     context.Background(),
+		cfg:     cfg,
+		out:     out,
+		outMu:   outMu,
+		trigger: make(chan struct{}, 1),
+		hist:    newHistory(cfg.historySize),
+		state:   newTracker(cfg.metricRecoveryConfirmPolls, cfg.escalateAfter),
+		stop:    func() {},
+		stale:   staleness{maxAge: cfg.maxDataAge},
+		errRate: &errorRate{window: cfg.errorRateWindow, threshold: cfg.errorRateThreshold},
+		budget:  newRetryBudget(cfg.retryBudget),
+		metrics: newMetrics(),
+		limiter: newLimiter(cfg.maxReqPerSec),
+		jsonl:   jsonl,
+		acks:    newAcks(),
+		ewma:    ewma{alpha: cfg.ewmaAlpha},
+		swap:    swapDetector{polls: cfg.swapDetectPolls},
+
+		capacity: capacityWatch{enabled: cfg.capacityReset},
+		flap:     newFlapDetector(cfg.flapThreshold, cfg.flapWindow),
+
+		warmupLeft: cfg.warmupPolls,
+		heartbeat:  heartbeat{interval: cfg.heartbeatInterval},
+		server:     cfg.serverLabel,
+
+		recordStates: make(map[string]*tracker),
+	}
+	m.client = newHTTPClient(cfg, m.httpsRedirect)
+	if cfg.oauth.tokenURL != "" {
+		m.auth = newTokenAuth(cfg.oauth, &http.Client{Transport: m.client.Transport, Timeout: cfg.httpTimeout})
+	}
+	m.fetcher = fetcherFunc(m.fetchAny)
+	m.fetch = m.fetchWithRetry
+	m.clock = systemClock{}
+	m.rand = newRand(cfg.randSeed, cfg.serverLabel)
+	if cfg.push.url != "" {
+		m.pusher = newPusher(cfg.push, cfg.serverLabel, cfg.notify.timeout, m.printf, func() { m.metrics.notifierFailed("pushgateway") })
+	}
+	return m
+}
+
+// checkOptions — текущие настройки проверок; см. cfgMu.
+func (m *monitor) checkOptions() checkOptions {
+	m.cfgMu.RLock()
+	defer m.cfgMu.RUnlock()
+	return m.cfg.check
+}
+
+func (m *monitor) printf(format string, args ...any) {
+	m.fprintf(m.out, format, args...)
+}
+
+func (m *monitor) fprintf(w io.Writer, format string, args ...any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cfg.logCorrelation && m.pollID != "" {
+		format = "[" + m.pollID + "] " + format
+	}
+	format = m.prefix + format
+	m.outMu.Lock()
+	defer m.outMu.Unlock()
+	fmt.Fprintf(w, format+"\n", args...)
+}
+
+// alertOut — поток уровня алерта (WARN_STREAM, CRIT_STREAM);
+// алерты без уровня (heartbeat и т.п.) идут в out.
+func (m *monitor) alertOut(severity string) io.Writer {
+	switch {
+	case severity == severityWarn && m.warnOut != nil:
+		return m.warnOut
+	case severity == severityCrit && m.critOut != nil:
+		return m.critOut
+	}
+	return m.out
+}
+
+// alertPrintf — printf в поток уровня алерта.
+func (m *monitor) alertPrintf(severity string) func(format string, args ...any) {
+	w := m.alertOut(severity)
+	return func(format string, args ...any) { m.fprintf(w, format, args...) }
+}
+
+// alertPrintln пишет строку в поток уровня алерта как есть, без префиксов
+// лога: машинные форматы (-format cef) разбираются построчно.
+func (m *monitor) alertPrintln(severity, line string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outMu.Lock()
+	defer m.outMu.Unlock()
+	fmt.Fprintln(m.alertOut(severity), line)
+}
+
+// requestPoll просит цикл опросить сервер немедленно, не дожидаясь интервала.
+func (m *monitor) requestPoll() {
+	select {
+	case m.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// run — единственный исполнитель опросов: плановые и внеочередные опросы
+// идут через один select, поэтому никогда не пересекаются.
+// С POLL_CRON первый опрос — в первый слот расписания, а не сразу.
+// Возвращается после отмены ctx.
+func (m *monitor) run(ctx context.Context, interval time.Duration) {
+	m.ctx = ctx
+	if m.warmupLeft > 0 {
+		m.printf("Warmup: alerts are not dispatched for the first %d polls.", m.warmupLeft)
+	}
+
+	if m.cfg.pollSchedule == nil {
+		m.tick()
+	}
+	for {
+		next, stop := m.clock.After(m.untilNextPoll(interval))
+		select {
+		case <-ctx.Done():
+			stop()
+			return
+		case <-next:
+		case <-m.trigger:
+		}
+		stop()
+		m.tick()
+	}
+}
+
+// untilNextPoll — пауза до следующего планового опроса: interval или
+// до ближайшего слота POLL_CRON.
+func (m *monitor) untilNextPoll(interval time.Duration) time.Duration {
+	if m.cfg.pollSchedule == nil {
+		return interval
+	}
+	now := m.clock.Now()
+	return m.cfg.pollSchedule.Next(now).Sub(now)
+}
+
+func (m *monitor) tick() {
+	defer m.beginPoll()()
+
+	// Первые WARMUP_POLLS опросов обновляют состояние, но ничего не рассылают
+	warming := m.warmupLeft > 0
+	if warming {
+		m.warmupLeft--
+		defer func() {
+			if m.warmupLeft == 0 {
+				m.printf("Warmup complete, alerts enabled.")
+			}
+		}()
+	}
+
+	release, err := m.pool.acquire(m.ctx)
+	if err != nil {
+		return // завершение, пока опрос ждал в очереди
+	}
+	recs, err := m.pollWithDeadline()
+	release()
+	if m.ctx.Err() != nil {
+		return // опрос прерван завершением — не ошибка сервера
+	}
+	m.counts.polls++
+	if errors.Is(err, errNoData) {
+		return // не успех и не ошибка: счётчики и состояние не меняются
+	}
+	if msg, ok := m.swap.observe(err); ok {
+		m.printf("%s", msg)
+	}
+	rateAlert, rateDue := m.checkErrorRate(err != nil)
+	rateDue = rateDue && !warming
+	if err != nil {
+		m.counts.failed++
+		m.okStreak = 0
+		if m.consecutiveErrors.Add(1) >= 3 && !m.errorPrinted && !warming {
+			m.printf("Unable to fetch server statistic.")
+			m.errorPrinted = true
+		}
+		if rateDue {
+			m.deliver([]Alert{rateAlert}, nil, m.clock.Now())
+		}
+		return
+	}
+	m.consecutiveErrors.Store(0)
+	m.okStreak++
+	m.recovered()
+	m.markReady()
+
+	now := m.clock.Now()
+	alerts := m.checkRecords(recs, now)
+	m.lastRecs = recs
+	m.saveState(now)
+	m.pushMetrics(recs[0].stats, now)
+	for _, metric := range m.acks.clearResolved(alerts) {
+		m.printf("Acknowledgement for %s cleared.", metric)
+	}
+	if warming {
+		return
+	}
+	if m.critExitDue(now) {
+		return
+	}
+	if rateDue {
+		alerts = append(alerts, rateAlert)
+	}
+	m.deliver(alerts, recs, now)
+}
+
+// deliver — общий путь алертов опроса: порядок ALERT_ORDER, фильтр
+// флаппинга, snooze, затем получатели. recs == nil — опрос неудачен:
+// сырой ответ не пишется, heartbeat не отправляется.
+func (m *monitor) deliver(alerts []Alert, recs []record, now time.Time) {
+	orderAlerts(alerts, m.cfg.alertOrder)
+	alerts, stable := m.flap.filter(alerts, now)
+	for _, msg := range stable {
+		m.printf("%s", msg)
+	}
+
+	// Во время snooze нарушения считаются, но не рассылаются
+	if m.snooze.suppress(countFiring(alerts)) {
+		return
+	}
+	if recs != nil {
+		m.logRaw(recs, alerts)
+		if a, ok := m.heartbeat.due(now, m.anyBreached()); ok {
+			alerts = append(alerts, a)
+		}
+	}
+	m.counts.alerts += countFiring(alerts)
+	m.dispatch(alerts)
+}
+
+// checkErrorRate учитывает исход опроса в ERROR_RATE_WINDOW; ok — пора
+// разослать алерт о доле ошибок (и при неудачном опросе, когда других нет).
+func (m *monitor) checkErrorRate(failed bool) (Alert, bool) {
+	now := m.clock.Now()
+	m.errRate.observe(failed, now)
+	return m.errRate.check(now)
+}
+
+// critExitDue: CRIT_EXIT_METRIC в CRIT дольше CRIT_EXIT_AFTER (у любой
+// записи ответа) — процесс завершается с exitCritHeld, чтобы внешний
+// супервизор мог заняться сервером.
+func (m *monitor) critExitDue(now time.Time) bool {
+	if m.cfg.critExitMetric == "" {
+		return false
+	}
+	for _, rec := range m.lastRecs {
+		label, tr := rec.label, m.state
+		if label != "" {
+			tr = m.recordStates[label]
+		}
+		state, since := tr.stateOf(m.cfg.critExitMetric)
+		if state != stateCrit || now.Sub(since) < m.cfg.critExitAfter {
+			continue
+		}
+		m.printf("!!! %s%s has been CRIT for %s (CRIT_EXIT_AFTER=%s): stopping with exit code %d for remediation.",
+			labelPrefix(label), m.cfg.critExitMetric, formatDuration(now.Sub(since)), m.cfg.critExitAfter, exitCritHeld)
+		m.critHeld.Store(true)
+		m.stop()
+		return true
+	}
+	return false
+}
+
+// recovered снимает состояние ошибки после успешного опроса. С
+// RECOVERY_CONFIRM_POLLS = N сообщение о восстановлении печатается после
+// N успехов подряд, а до тех пор повторная ошибка не печатается заново.
+func (m *monitor) recovered() {
+	confirm := m.cfg.recoveryConfirmPolls
+	if confirm == 0 {
+		m.errorPrinted = false
+		return
+	}
+	if m.errorPrinted && m.okStreak >= confirm {
+		m.printf("Server statistic available again.")
+		m.errorPrinted = false
+	}
+}
+
+// runOnce выполняет один опрос и возвращает код выхода.
+func (m *monitor) runOnce(failOnAlert bool) int {
+	defer m.beginPoll()()
+
+	recs, err := m.pollWithDeadline()
+	if errors.Is(err, errNoData) {
+		m.printf("No new stats data.")
+		return exitOK
+	}
+	if err != nil {
+		m.printf("Unable to fetch server statistic: %v", err)
+		return exitError
+	}
+	alerts := m.checkRecordsOnce(recs, m.clock.Now())
+	orderAlerts(alerts, m.cfg.alertOrder)
+	m.logRaw(recs, alerts)
+	m.dispatch(alerts)
+	if failOnAlert && len(alerts) > 0 {
+		names := make([]string, len(alerts))
+		for i, a := range alerts {
+			names[i] = a.Metric
+			if a.Record != "" {
+				names[i] = a.Record + "/" + a.Metric
+			}
+		}
+		m.printf("Check failed: %s", strings.Join(names, ", "))
+		return exitAlert
+	}
+	return exitOK
+}
+
+// pollOnce получает и разбирает статистику. В историю (и /stats) попадает
+// первая запись ответа, в METRICS_JSONL — все.
+func (m *monitor) pollOnce() ([]record, error) {
+	start := m.clock.Now()
+	body, err := m.fetch()
+	if err != nil {
+		return nil, err
+	}
+	latency := m.clock.Now().Sub(start)
+	recs, err := parseRecords(body, m.cfg.parser)
+	at := m.clock.Now()
+	parse := at.Sub(start) - latency
+	if m.cfg.verbose {
+		m.printf("Poll timing: network %s, parse %s.", latency.Round(time.Microsecond), parse)
+	}
+	if err != nil {
+		m.captureBody(body)
+		return nil, err
+	}
+	for i, rec := range recs {
+		if len(rec.stats.Missing) > 0 {
+			m.printf("%sDropped unparseable fields: %s", labelPrefix(rec.label), strings.Join(rec.stats.Missing, ", "))
+		}
+		if rec.stats.Ignored > 0 && !m.extraFieldsLogged {
+			m.printf("%sIgnoring %d extra fields beyond the known ones (STRICT_FIELDS=false).", labelPrefix(rec.label), rec.stats.Ignored)
+			m.extraFieldsLogged = true
+		}
+		smp := sample{At: at, Latency: latency, Parse: parse, Stats: rec.stats, Derived: derive(rec.stats, m.cfg.cpuCores)}
+		if i == 0 {
+			m.hist.add(smp)
+		}
+		if m.jsonl != nil {
+			if err := m.jsonl.write(smp, m.server, rec.label, m.pollID, m.checkOptions()); err != nil {
+				m.printf("Unable to write metrics log: %v", err)
+			}
+		}
+	}
+	return recs, nil
+}
+
+// runCounts — итоги запуска для сводки -max-runtime. Меняются только из цикла.
+type runCounts struct {
+	polls, failed, alerts int
+}
+
+func (c runCounts) summary() string {
+	return fmt.Sprintf("%d polls, %d failed, %d alerts dispatched", c.polls, c.failed, c.alerts)
+}
+
+func countFiring(alerts []Alert) int {
+	n := 0
+	for _, a := range alerts {
+		if a.Status == statusFiring {
+			n++
+		}
+	}
+	return n
+}
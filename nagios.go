@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Коды выхода и префиксы плагина Nagios/Icinga
+const (
+	nagiosOK       = 0
+	nagiosWarning  = 1
+	nagiosCritical = 2
+	nagiosUnknown  = 3
+)
+
+var nagiosStatus = [...]string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// runCheck выполняет один опрос и печатает строку в формате плагина Nagios:
+// статус по худшему алерту, сообщения и perfdata после «|».
+func (m *monitor) runCheck() int {
+	recs, err := m.pollOnce()
+	if err != nil {
+		m.printf("%s: Unable to fetch server statistic: %v", nagiosStatus[nagiosUnknown], err)
+		return nagiosUnknown
+	}
+
+	alerts := m.checkRecordsOnce(recs, time.Now())
+	orderAlerts(alerts, m.cfg.alertOrder)
+	code := nagiosOK
+	msgs := make([]string, 0, len(alerts))
+	for _, a := range alerts {
+		msgs = append(msgs, a.Message)
+		switch {
+		case a.Severity == severityWarn:
+			code = max(code, nagiosWarning)
+		default:
+			code = nagiosCritical
+		}
+	}
+	summary := "all metrics within thresholds"
+	if len(msgs) > 0 {
+		summary = strings.Join(msgs, "; ")
+	}
+	perf := make([]string, len(recs))
+	for i, rec := range recs {
+		perf[i] = perfdata(rec.stats, rec.label, m.cfg.check.warn, m.cfg.check.crit)
+	}
+	m.printf("%s: %s | %s", nagiosStatus[code], summary, strings.Join(perf, " "))
+	return code
+}
+
+// perfdata: label=value[UOM];warn;crit через пробел, как требует Nagios.
+// Метки записей многострочного ответа становятся префиксом: web1_load=….
+func perfdata(s Stats, label string, w warnThresholds, c critThresholds) string {
+	prefix := ""
+	if label != "" {
+		prefix = label + "_"
+	}
+	var b strings.Builder
+	item := func(label, value, uom string, warn, crit float64) {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		warnStr := ""
+		if warn > 0 {
+			warnStr = strconv.FormatFloat(warn, 'f', -1, 64)
+		}
+		fmt.Fprintf(&b, "%s%s=%s%s;%s;%s", prefix, label, value, uom, warnStr, strconv.FormatFloat(crit, 'f', -1, 64))
+	}
+	loadUOM := ""
+	if s.LoadPercent {
+		loadUOM = "%"
+	}
+	item(metricLoad, strconv.FormatFloat(s.LoadAvg, 'f', -1, 64), loadUOM, w.load, c.load)
+	pairs := []struct {
+		metric      string
+		used, total uint64
+		warn, crit  int
+	}{
+		{metricMem, s.UsedRAM, s.TotalRAM, w.mem, c.mem},
+		{metricDisk, s.UsedDisk, s.TotalDisk, w.disk, c.disk},
+		{metricNet, s.NetUsed, s.NetCapacity, w.net, c.net},
+	}
+	for _, p := range pairs {
+		if p.total == 0 {
+			continue
+		}
+		item(p.metric, strconv.FormatUint(p.used*100/p.total, 10), "%", float64(p.warn), float64(p.crit))
+	}
+	return b.String()
+}
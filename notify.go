@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Notifier — получатель алертов: stdout, вебхук, счётчики Prometheus и т.д.
+type Notifier interface {
+	Notify(Alert) error
+}
+
+type sink struct {
+	name     string
+	n        Notifier
+	external bool // молчит о подтверждённых через /ack нарушениях
+	minLevel int  // severityRank; алерты ниже уровня не доставляются
+}
+
+// dispatch рассылает алерты всем получателям. Ошибка или паника одного
+// получателя не мешает остальным.
+func (m *monitor) dispatch(alerts []Alert) {
+	m.stampAlerts(alerts)
+	for _, a := range alerts {
+		for _, s := range m.sinks {
+			if s.external && a.Status == statusFiring && a.Record == "" && m.acks.acked(a.Metric) {
+				continue
+			}
+			if severityRank(a.Severity) < s.minLevel {
+				continue
+			}
+			if err := safeNotify(s.n, a); err != nil {
+				m.printf("Notifier %s failed: %v", s.name, err)
+			}
+		}
+	}
+}
+
+func safeNotify(n Notifier, a Alert) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return n.Notify(a)
+}
+
+// textNotifier печатает алерт строкой, как и раньше. С durations
+// к алерту дописывается длительность нарушения и печатаются восстановления.
+// printf выбирает поток по уровню алерта. С explain к нарушению
+// дописывается расчёт: "... (memory 7000/8000 = 87.5% > threshold 80%)".
+type textNotifier struct {
+	printf    func(severity string) func(format string, args ...any)
+	durations bool
+	explain   bool
+}
+
+func (t textNotifier) Notify(a Alert) error {
+	printf, meta := t.printf(a.Severity), metaPrefix(a)
+	if t.explain && a.Explain != "" && a.Status == statusFiring {
+		a.Message += " (" + a.Explain + ")"
+	}
+	switch {
+	case a.Status == statusOK, a.Status == statusFlapping:
+		printf("%s%s", meta, a.Message)
+	case !t.durations && a.Status == statusResolved:
+	case !t.durations:
+		printf("%s%s", meta, a.Message)
+	case a.Status == statusResolved:
+		printf("%s%s", meta, a.Message)
+	default:
+		printf("%s%s (for %s)", meta, a.Message, formatDuration(a.BreachedFor()))
+	}
+	return nil
+}
+
+const (
+	webhookQueueSize   = 64
+	notifyRetryBackoff = 200 * time.Millisecond // удваивается с каждой попыткой
+)
+
+// notifyOptions — общие настройки HTTP-получателей.
+type notifyOptions struct {
+	timeout time.Duration
+	retries int // повторов после первой неудачной попытки
+}
+
+// webhookNotifier отправляет алерты POST-запросом с JSON в фоне,
+// чтобы медленный приёмник не задерживал опрос.
+type webhookNotifier struct {
+	url     string
+	client  *http.Client
+	retries int
+	queue   chan any // Alert или batchPayload
+	logf    func(format string, args ...any)
+	failed  func() // доставка не удалась после всех повторов
+	sleep   func(time.Duration)
+	pending sync.WaitGroup // в очереди и в отправке
+}
+
+// drainer — получатель с фоновой доставкой; drain ждёт, пока уйдёт
+// накопленное, но не дольше timeout.
+type drainer interface {
+	drain(timeout time.Duration)
+}
+
+type batchPayload struct {
+	Alerts []Alert `json:"alerts"`
+}
+
+func newWebhookNotifier(url string, opts notifyOptions, logf func(format string, args ...any), failed func()) *webhookNotifier {
+	w := &webhookNotifier{
+		url:     url,
+		client:  &http.Client{Timeout: opts.timeout},
+		retries: opts.retries,
+		queue:   make(chan any, webhookQueueSize),
+		logf:    logf,
+		failed:  failed,
+		sleep:   time.Sleep,
+	}
+	go w.loop()
+	return w
+}
+
+func (w *webhookNotifier) Notify(a Alert) error {
+	return w.enqueue(a)
+}
+
+// NotifyBatch шлёт пачку одним запросом: {"alerts": [...]}.
+func (w *webhookNotifier) NotifyBatch(alerts []Alert) error {
+	return w.enqueue(batchPayload{Alerts: alerts})
+}
+
+func (w *webhookNotifier) enqueue(payload any) error {
+	w.pending.Add(1)
+	select {
+	case w.queue <- payload:
+		return nil
+	default:
+		w.pending.Done()
+		return errors.New("queue is full, alert dropped")
+	}
+}
+
+func (w *webhookNotifier) loop() {
+	for payload := range w.queue {
+		if err := w.deliver(payload); err != nil {
+			w.failed()
+			w.logf("Webhook delivery failed after %d attempts: %v", w.retries+1, err)
+		}
+		w.pending.Done()
+	}
+}
+
+func (w *webhookNotifier) drain(timeout time.Duration) {
+	waitTimeout(&w.pending, timeout)
+}
+
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+// deliver повторяет post до NOTIFY_RETRIES раз с растущей паузой.
+func (w *webhookNotifier) deliver(payload any) error {
+	err := w.post(payload)
+	for attempt := 0; attempt < w.retries && err != nil; attempt++ {
+		w.sleep(backoffDelay(notifyRetryBackoff, attempt))
+		err = w.post(payload)
+	}
+	return err
+}
+
+func (w *webhookNotifier) post(payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("bad status: %s", resp.Status)
+	}
+	return nil
+}
+
+// newWebhook — вебхук с пачками по NOTIFY_BATCH_WINDOW; name — метка
+// неудачных доставок в /metrics.
+func (m *monitor) newWebhook(url, name string) Notifier {
+	var n Notifier = newWebhookNotifier(url, m.cfg.notify, m.printf, func() { m.metrics.notifierFailed(name) })
+	if m.cfg.notifyBatchWindow > 0 {
+		n = newBatcher(m.cfg.notifyBatchWindow, n, m.printf)
+	}
+	return n
+}
+
+func (m *monitor) buildSinks(names []string) error {
+	for _, name := range names {
+		var n Notifier
+		external := false
+		switch name {
+		case "stdout":
+			n = textNotifier{printf: m.alertPrintf, durations: m.cfg.alertDurations, explain: m.explain}
+			if m.alertFmt == formatCEF {
+				n = cefNotifier{println: m.alertPrintln}
+			}
+		case "webhook":
+			if m.cfg.webhookURL == "" && len(m.cfg.webhookRoutes) == 0 {
+				return errors.New("webhook notifier requires WEBHOOK_URL")
+			}
+			external = true
+			if m.cfg.webhookURL != "" {
+				n = m.newWebhook(m.cfg.webhookURL, name)
+			}
+			if len(m.cfg.webhookRoutes) > 0 {
+				r := webhookRouter{routes: make(map[string]Notifier, len(m.cfg.webhookRoutes)), fallback: n}
+				for metric, u := range m.cfg.webhookRoutes {
+					r.routes[metric] = m.newWebhook(u, name+"_"+metric)
+				}
+				n = r
+			}
+		case "prometheus":
+			if m.cfg.adminAddr == "" {
+				return errors.New("prometheus notifier requires ADMIN_ADDR")
+			}
+			n = m.metrics
+		default:
+			return fmt.Errorf("unknown notifier %q", name)
+		}
+		if external && m.fleet != nil {
+			n = m.fleet.wrap(name, n)
+		}
+		m.sinks = append(m.sinks, sink{
+			name:     name,
+			n:        n,
+			external: external,
+			minLevel: severityRank(m.cfg.minLevels[name]),
+		})
+	}
+	return nil
+}
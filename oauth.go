@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// oauthConfig — OAuth2 client credentials для запросов статистики.
+type oauthConfig struct {
+	tokenURL     string // "" — без токена
+	clientID     string
+	clientSecret string
+	scopes       []string
+}
+
+// tokenAuth выдаёт bearer-токен: кэширует его и получает новый незадолго
+// до истечения (oauth2.ReuseTokenSource), а после 401 — сбросом кэша.
+type tokenAuth struct {
+	conf *clientcredentials.Config
+	ctx  context.Context // несёт HTTP-клиент для запросов к TOKEN_URL
+
+	mu     sync.Mutex
+	src    oauth2.TokenSource
+	failed bool // последняя попытка получить токен не удалась
+}
+
+func newTokenAuth(c oauthConfig, client *http.Client) *tokenAuth {
+	a := &tokenAuth{
+		conf: &clientcredentials.Config{
+			ClientID:     c.clientID,
+			ClientSecret: c.clientSecret,
+			TokenURL:     c.tokenURL,
+			Scopes:       c.scopes,
+		},
+		ctx: context.WithValue(context.Background(), oauth2.HTTPClient, client),
+	}
+	a.src = a.conf.TokenSource(a.ctx)
+	return a
+}
+
+// token возвращает действующий токен. first — ошибка первая после успеха:
+// её стоит напечатать, повторные — только посчитать.
+func (a *tokenAuth) token() (tok string, first bool, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	t, err := a.src.Token()
+	if err != nil {
+		first = !a.failed
+		a.failed = true
+		// RetrieveError печатает тело ответа с новой строки — в лог только код
+		var re *oauth2.RetrieveError
+		if errors.As(err, &re) && re.Response != nil {
+			return "", first, fmt.Errorf("token: bad status: %s", re.Response.Status)
+		}
+		return "", first, fmt.Errorf("token: %w", err)
+	}
+	a.failed = false
+	return t.AccessToken, false, nil
+}
+
+// invalidate забывает закэшированный токен: сервер его не принял.
+func (a *tokenAuth) invalidate() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.src = a.conf.TokenSource(a.ctx)
+}
+
+// bearer получает токен для запроса; ошибка получения печатается один раз
+// за серию и учитывается в token_refresh_failures_total.
+func (m *monitor) bearer() (string, error) {
+	if m.auth == nil {
+		return "", nil
+	}
+	tok, first, err := m.auth.token()
+	if err != nil {
+		m.metrics.tokenFailed()
+		if first {
+			m.printf("Token refresh failed: %v", err)
+		}
+	}
+	return tok, err
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Форматы вывода -parse-only
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+// parsedRecord — строка вывода -parse-only в JSON: поля Stats плюс метка записи.
+type parsedRecord struct {
+	Record string `json:"record,omitempty"`
+	Stats
+}
+
+// runParseOnly получает и разбирает один ответ и печатает статистику
+// в stdout без проверки порогов: по строке на запись. Ошибки — в stderr.
+func (m *monitor) runParseOnly(format string, stdout, stderr io.Writer) int {
+	defer m.beginPoll()()
+
+	recs, err := m.pollOnce()
+	if errors.Is(err, errNoData) {
+		fmt.Fprintln(stderr, "No new stats data.")
+		return exitOK
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Unable to fetch server statistic: %v\n", err)
+		return exitError
+	}
+	for _, rec := range recs {
+		if err := writeParsed(stdout, format, rec, m.cfg.parse.extraFields); err != nil {
+			fmt.Fprintf(stderr, "write: %v\n", err)
+			return exitError
+		}
+	}
+	return exitOK
+}
+
+func validParseFormat(format string) error {
+	if format != formatJSON && format != formatCSV {
+		return fmt.Errorf("-parse-only: -format must be %q or %q", formatJSON, formatCSV)
+	}
+	return nil
+}
+
+// writeParsed: CSV — в порядке исходного формата, объёмы в байтах,
+// отсутствующие необязательные поля пустые; метка записи — первым полем.
+func writeParsed(w io.Writer, format string, rec record, extras []string) error {
+	if format == formatJSON {
+		return json.NewEncoder(w).Encode(parsedRecord{Record: rec.label, Stats: rec.stats})
+	}
+	s := rec.stats
+	var fields []string
+	if rec.label != "" {
+		fields = append(fields, rec.label)
+	}
+	fields = append(fields, strconv.FormatFloat(s.LoadAvg, 'f', -1, 64))
+	for _, v := range [...]uint64{s.TotalRAM, s.UsedRAM, s.TotalDisk, s.UsedDisk, s.NetCapacity, s.NetUsed} {
+		fields = append(fields, strconv.FormatUint(v, 10))
+	}
+	for _, name := range extras {
+		v, ok := s.Extra[name]
+		if !ok {
+			fields = append(fields, "")
+			continue
+		}
+		fields = append(fields, strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	_, err := fmt.Fprintln(w, strings.Join(fields, ","))
+	return err
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Parser разбирает тело ответа в статистику; формат выбирается PARSER.
+type Parser interface {
+	Parse([]byte) (Stats, error)
+}
+
+const (
+	parserCSV       = "csv"
+	parserJSON      = "json"
+	parserCSVHeader = "csv-header"
+)
+
+var parsers = map[string]func(parseOptions) Parser{
+	parserCSV:        func(o parseOptions) Parser { return csvParser{o} },
+	parserJSON:       func(o parseOptions) Parser { return jsonParser{o} },
+	parserCSVHeader:  func(o parseOptions) Parser { return csvHeaderParser{o} },
+	parserPrometheus: func(o parseOptions) Parser { return prometheusParser{o} },
+}
+
+func newParser(name string, opts parseOptions) (Parser, error) {
+	mk, ok := parsers[name]
+	if !ok {
+		names := make([]string, 0, len(parsers))
+		for n := range parsers {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown parser %q (want %s)", name, strings.Join(names, ", "))
+	}
+	return mk(opts), nil
+}
+
+// Имена полей в форматах с именами: как в JSON /stats, затем EXTRA_FIELDS
+var namedCoreFields = [...]string{"load_avg", "total_ram", "used_ram", "total_disk", "used_disk", "net_capacity", "net_used"}
+
+// csvParser — исходный формат: одна строка значений через запятую.
+// Многострочные ответы с метками записей разбирает parseRecords.
+type csvParser struct{ opts parseOptions }
+
+func (p csvParser) Parse(b []byte) (Stats, error) {
+	return ParseStats(string(b), p.opts)
+}
+
+// jsonParser: {"load_avg": 1.5, "total_ram": 8000, ..., "temp": 61}.
+// Известные поля должны быть числами, неизвестные ключи пропускаются.
+type jsonParser struct{ opts parseOptions }
+
+func (p jsonParser) Parse(b []byte) (Stats, error) {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return Stats{}, errors.New("empty body")
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber() // load avg выводится как пришёл
+	var obj map[string]any
+	if err := dec.Decode(&obj); err != nil {
+		return Stats{}, fmt.Errorf("decode json: %w", err)
+	}
+	if dec.Decode(&struct{}{}) != io.EOF {
+		return Stats{}, errors.New("decode json: trailing data after object")
+	}
+	if p.opts.jsonSchema != nil {
+		if err := p.opts.jsonSchema.Validate(obj); err != nil {
+			return Stats{}, fmt.Errorf("json schema: %s", strings.TrimPrefix(err.Error(), "jsonschema: "))
+		}
+	}
+	// Строка "8000" вместо числа — признак сменившегося формата, а не значение
+	for _, name := range slices.Concat(namedCoreFields[:], p.opts.extraFields) {
+		if v := obj[name]; v != nil {
+			if _, ok := v.(json.Number); !ok {
+				return Stats{}, fmt.Errorf("field %q: want number, got %s", name, jsonType(v))
+			}
+		}
+	}
+	values := make(map[string]string, len(obj))
+	for k, v := range obj {
+		if n, ok := v.(json.Number); ok {
+			values[k] = n.String() // как в теле
+		}
+	}
+	return parseNamed(values, p.opts)
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// csvHeaderParser: строка заголовка с именами полей и строка значений;
+// порядок колонок любой, незнакомые колонки пропускаются.
+type csvHeaderParser struct{ opts parseOptions }
+
+func (p csvHeaderParser) Parse(b []byte) (Stats, error) {
+	var lines []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	switch {
+	case len(lines) == 0:
+		return Stats{}, errors.New("empty body")
+	case len(lines) != 2:
+		return Stats{}, fmt.Errorf("want header and one row, got %d lines", len(lines))
+	}
+	header, row := strings.Split(lines[0], ","), strings.Split(lines[1], ",")
+	if len(header) != len(row) {
+		return Stats{}, fmt.Errorf("header has %d columns, row has %d", len(header), len(row))
+	}
+	values := make(map[string]string, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, dup := values[name]; dup {
+			return Stats{}, fmt.Errorf("duplicate column %q", name)
+		}
+		values[name] = strings.TrimSpace(row[i])
+	}
+	return parseNamed(values, p.opts)
+}
+
+// parseNamed раскладывает значения по именам в порядке CSV и разбирает
+// тем же кодом, что и CSV: проверки и ошибки у всех форматов общие.
+func parseNamed(values map[string]string, opts parseOptions) (Stats, error) {
+	names := slices.Concat(namedCoreFields[:], opts.extraFields)
+	i := 0
+	next := func() (string, bool) {
+		v, ok := values[names[i]]
+		i++
+		return v, ok
+	}
+	return parseFields(next, opts.extraFields, opts)
+}
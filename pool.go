@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// pollPool — общий для всех серверов потолок одновременных опросов
+// (MAX_CONCURRENCY). Опросы сверх него ждут в очереди свободного слота.
+// nil — без ограничения.
+type pollPool struct {
+	slots   chan struct{}
+	waiting atomic.Int64
+}
+
+func newPollPool(n int) *pollPool {
+	if n <= 0 {
+		return nil
+	}
+	return &pollPool{slots: make(chan struct{}, n)}
+}
+
+// acquire ждёт слот; ошибка — ctx отменён, пока ждали.
+func (p *pollPool) acquire(ctx context.Context) (release func(), err error) {
+	if p == nil {
+		return func() {}, nil
+	}
+	p.waiting.Add(1)
+	defer p.waiting.Add(-1)
+	select {
+	case p.slots <- struct{}{}:
+		return func() { <-p.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// poolStats — очередь и занятые слоты для /metrics и Pushgateway.
+type poolStats struct {
+	Size    int   `json:"size"`
+	Active  int   `json:"active"`
+	Waiting int64 `json:"waiting"`
+}
+
+func (p *pollPool) stats() *poolStats {
+	if p == nil {
+		return nil
+	}
+	return &poolStats{Size: cap(p.slots), Active: len(p.slots), Waiting: p.waiting.Load()}
+}
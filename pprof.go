@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// startPprof поднимает отдельный сервер профилирования, если задан PPROF_ADDR.
+// Свой mux, а не http.DefaultServeMux: обработчики pprof не должны попасть
+// на служебный сервер ADMIN_ADDR.
+func (m *monitor) startPprof(addr string) {
+	if addr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			m.printf("Pprof server stopped: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+const probeTimeout = 3 * time.Second
+
+// Коды -probe: Docker HEALTHCHECK понимает только 0 и 1
+const (
+	probeHealthy   = 0
+	probeUnhealthy = 1
+)
+
+// runProbe опрашивает /health запущенного экземпляра по addr
+// (по умолчанию ADMIN_ADDR) и печатает итог одной строкой.
+func runProbe(addr string, stdout, stderr io.Writer) int {
+	if addr == "" {
+		addr = os.Getenv("ADMIN_ADDR")
+	}
+	status, err := probe(addr)
+	if err != nil {
+		fmt.Fprintf(stderr, "probe: %v\n", err)
+		return probeUnhealthy
+	}
+	fmt.Fprintln(stdout, status)
+	if status != "ok" {
+		return probeUnhealthy
+	}
+	return probeHealthy
+}
+
+func probe(addr string) (string, error) {
+	if addr == "" {
+		return "", errors.New("no address: pass one after -probe or set ADMIN_ADDR")
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	// ":8080" слушает все интерфейсы, а сами мы на localhost
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	client := &http.Client{Timeout: probeTimeout}
+	resp, err := client.Get("http://" + net.JoinHostPort(host, port) + "/health")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var h healthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&h); err != nil {
+		return "", fmt.Errorf("decode health: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK && h.Status == "ok" {
+		return "", fmt.Errorf("bad status: %s", resp.Status)
+	}
+	return h.Status, nil
+}
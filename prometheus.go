@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const parserPrometheus = "prometheus"
+
+// promSelector выбирает серию: имя метрики и метки, которые должны совпасть
+// (`node_filesystem_size_bytes{mountpoint="/"}`); прочие метки не важны.
+type promSelector struct {
+	name   string
+	labels map[string]string
+}
+
+func (s promSelector) String() string {
+	if len(s.labels) == 0 {
+		return s.name
+	}
+	return s.name + "{" + formatLabels(s.labels) + "}"
+}
+
+func (s promSelector) matches(name string, labels map[string]string) bool {
+	if name != s.name {
+		return false
+	}
+	for k, v := range s.labels {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// parsePromMetrics читает PROM_METRICS: "load_avg=node_load1,total_disk=
+// node_filesystem_size_bytes{mountpoint=\"/\"}". Поле без пары ищется
+// по собственному имени (load_avg, total_ram, ...).
+func parsePromMetrics(v string, fields []string) (map[string]promSelector, error) {
+	sel := make(map[string]promSelector, len(fields))
+	for _, f := range fields {
+		sel[f] = promSelector{name: f}
+	}
+	if v == "" {
+		return sel, nil
+	}
+	for _, pair := range splitOutsideBraces(v) {
+		field, expr, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("expected field=metric, got %q", pair)
+		}
+		if _, known := sel[field]; !known {
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+		name, labels, rest, err := parsePromSeries(strings.TrimSpace(expr))
+		if err != nil || rest != "" {
+			return nil, fmt.Errorf("invalid selector for %s: %q", field, expr)
+		}
+		sel[field] = promSelector{name: name, labels: labels}
+	}
+	return sel, nil
+}
+
+func formatPromMetrics(sel map[string]promSelector) string {
+	fields := make([]string, 0, len(sel))
+	for f := range sel {
+		fields = append(fields, f)
+	}
+	slices.Sort(fields)
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		parts[i] = f + "=" + sel[f].String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// splitOutsideBraces режет по запятым вне {...}: внутри — запятые между метками.
+func splitOutsideBraces(v string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range v {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, v[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, v[start:])
+}
+
+// prometheusParser читает текстовый формат Prometheus (как у node_exporter)
+// и берёт значения серий по PROM_METRICS. Каждому полю должна подходить
+// ровно одна серия.
+type prometheusParser struct{ opts parseOptions }
+
+func (p prometheusParser) Parse(b []byte) (Stats, error) {
+	if strings.TrimSpace(string(b)) == "" {
+		return Stats{}, errors.New("empty body")
+	}
+	// Для каждого нужного поля — найденное значение и число подходящих серий
+	found := make(map[string]float64, len(p.opts.promMetrics))
+	count := make(map[string]int, len(p.opts.promMetrics))
+	for n, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, labels, rest, err := parsePromSeries(line)
+		if err != nil {
+			return Stats{}, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return Stats{}, fmt.Errorf("line %d: missing value", n+1)
+		}
+		valueField := fields[0]
+		for field, sel := range p.opts.promMetrics {
+			if !sel.matches(name, labels) {
+				continue
+			}
+			v, err := strconv.ParseFloat(valueField, 64)
+			if err != nil {
+				return Stats{}, fmt.Errorf("line %d: invalid value %q for %s", n+1, valueField, sel)
+			}
+			found[field] = v
+			count[field]++
+		}
+	}
+
+	values := make(map[string]string, len(found))
+	for field, sel := range p.opts.promMetrics {
+		switch c := count[field]; {
+		case c > 1:
+			return Stats{}, fmt.Errorf("metric %s for %s matches %d series, add labels to PROM_METRICS", sel, field, c)
+		case c == 0 && slices.Contains(namedCoreFields[:], field) && !p.opts.lenient:
+			return Stats{}, fmt.Errorf("metric %s for %s not found", sel, field)
+		case c == 1:
+			values[field] = formatPromValue(field, found[field])
+		}
+	}
+	return parseNamed(values, p.opts)
+}
+
+// formatPromValue: объёмы в экспозиции — float ("8.0e+09"), а поля Stats
+// целые; дробная часть байтов округляется.
+func formatPromValue(field string, v float64) string {
+	if slices.Contains(namedCoreFields[1:], field) && v >= 0 {
+		v = math.Round(v)
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// parsePromSeries разбирает начало строки экспозиции: имя, метки и остаток.
+func parsePromSeries(line string) (name string, labels map[string]string, rest string, err error) {
+	i := strings.IndexFunc(line, func(r rune) bool { return r == '{' || r == ' ' || r == '\t' })
+	if i < 0 {
+		return line, nil, "", nil
+	}
+	name, rest = line[:i], line[i:]
+	if name == "" {
+		return "", nil, "", errors.New("missing metric name")
+	}
+	if rest[0] != '{' {
+		return name, nil, rest, nil
+	}
+	labels = make(map[string]string)
+	rest = rest[1:]
+	for {
+		rest = strings.TrimLeft(rest, " \t,")
+		if strings.HasPrefix(rest, "}") {
+			return name, labels, rest[1:], nil
+		}
+		key, tail, ok := strings.Cut(rest, "=")
+		if !ok || !strings.HasPrefix(strings.TrimSpace(tail), `"`) {
+			return "", nil, "", fmt.Errorf("invalid labels in %q", line)
+		}
+		val, tail, err := unquoteLabel(strings.TrimSpace(tail))
+		if err != nil {
+			return "", nil, "", fmt.Errorf("invalid labels in %q", line)
+		}
+		labels[strings.TrimSpace(key)] = val
+		rest = tail
+	}
+}
+
+// unquoteLabel читает значение метки в кавычках с экранированием \\, \" и \n.
+func unquoteLabel(s string) (val, rest string, err error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			return b.String(), s[i+1:], nil
+		case c == '\\' && i+1 < len(s):
+			i++
+			if s[i] == 'n' {
+				b.WriteByte('\n')
+			} else {
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", errors.New("unterminated label value")
+}
+
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + strconv.Quote(labels[k])
+	}
+	return strings.Join(parts, ",")
+}
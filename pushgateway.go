@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// pushOptions — PUSHGATEWAY_*.
+type pushOptions struct {
+	url         string
+	job         string
+	bucket      float64       // шаг корзины, процентных пунктов доли
+	maxInterval time.Duration // пушить не реже, даже без изменений
+}
+
+// pusher отправляет метрики сервера в Prometheus Pushgateway, но только когда
+// доля load, mem, disk или net перешла в другую корзину (шаг bucket) или
+// с прошлого пуша прошло maxInterval. Группа — job и instance = метка
+// сервера, так что серверы из HOSTS не перетирают друг друга.
+//
+// Отправка идёт в фоне; если она не успевает, ждёт только последнее тело.
+type pusher struct {
+	opts   pushOptions
+	url    string
+	client *http.Client
+	queue  chan []byte
+	logf   func(format string, args ...any)
+	failed func()
+
+	// Меняются только из цикла опроса
+	last   map[string]int
+	lastAt time.Time
+}
+
+func newPusher(opts pushOptions, server string, timeout time.Duration, logf func(format string, args ...any), failed func()) *pusher {
+	p := &pusher{
+		opts:   opts,
+		url:    strings.TrimSuffix(opts.url, "/") + "/metrics/job/" + url.PathEscape(opts.job) + "/instance/" + url.PathEscape(server),
+		client: &http.Client{Timeout: timeout},
+		queue:  make(chan []byte, 1),
+		logf:   logf,
+		failed: failed,
+	}
+	go p.loop()
+	return p
+}
+
+// due решает, пора ли пушить снимок s.
+func (p *pusher) due(s Stats, opts checkOptions, now time.Time) bool {
+	cur := make(map[string]int, 4)
+	for _, metric := range []string{metricLoad, metricMem, metricDisk, metricNet} {
+		if u, ok := s.usage(metric, opts); ok {
+			cur[metric] = int(math.Floor(u * 100 / p.opts.bucket))
+		}
+	}
+	changed := p.last == nil || now.Sub(p.lastAt) >= p.opts.maxInterval || len(cur) != len(p.last)
+	for metric, b := range cur {
+		if prev, ok := p.last[metric]; !ok || prev != b {
+			changed = true
+		}
+	}
+	if changed {
+		p.last, p.lastAt = cur, now
+	}
+	return changed
+}
+
+// push ставит тело в очередь, заменяя ещё не отправленное.
+func (p *pusher) push(body []byte) {
+	select {
+	case p.queue <- body:
+	default:
+		select {
+		case <-p.queue:
+		default:
+		}
+		p.queue <- body
+	}
+}
+
+func (p *pusher) loop() {
+	for body := range p.queue {
+		if err := p.put(body); err != nil {
+			p.failed()
+			p.logf("Pushgateway push failed: %v", err)
+		}
+	}
+}
+
+// put заменяет всю группу: PUT, а не POST, чтобы не оставались метрики,
+// которых в новом снимке нет.
+func (p *pusher) put(body []byte) error {
+	req, err := http.NewRequest(http.MethodPut, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("bad status: %s", resp.Status)
+	}
+	return nil
+}
+
+// pushMetrics после успешного опроса отдаёт в Pushgateway то же, что /metrics.
+func (m *monitor) pushMetrics(s Stats, now time.Time) {
+	if m.pusher == nil || !m.pusher.due(s, m.checkOptions(), now) {
+		return
+	}
+	var b bytes.Buffer
+	m.writeMetrics(&b, now)
+	m.pusher.push(b.Bytes())
+}
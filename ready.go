@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"os"
+)
+
+// markReady срабатывает после первого успешного разбора: /ready начинает
+// отвечать 200, а READY_FILE (если задан) создаётся.
+func (m *monitor) markReady() {
+	if m.ready.Swap(true) || m.cfg.readyFile == "" {
+		return
+	}
+	if err := os.WriteFile(m.cfg.readyFile, nil, 0o644); err != nil {
+		m.printf("Unable to create ready file: %v", err)
+	}
+}
+
+// unmarkReady убирает READY_FILE при завершении.
+func (m *monitor) unmarkReady() {
+	if !m.ready.Load() || m.cfg.readyFile == "" {
+		return
+	}
+	if err := os.Remove(m.cfg.readyFile); err != nil && !os.IsNotExist(err) {
+		m.printf("Unable to remove ready file: %v", err)
+	}
+}
+
+func (m *monitor) handleReady(w http.ResponseWriter, _ *http.Request) {
+	if !m.ready.Load() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
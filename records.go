@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// record — одна запись ответа. Обычно ответ — одна строка без метки;
+// многострочный ответ содержит по записи на строку, и каждая строка
+// начинается с идентификатора: "web1,0.5,8000,...".
+type record struct {
+	label string // "" — однострочный ответ
+	raw   string // строка ответа без пробелов по краям, для VERBOSE
+	stats Stats
+}
+
+// parseRecords разбирает тело выбранным парсером. Многострочный ответ
+// с метками записей бывает только у csv; прочие форматы — одна запись.
+func parseRecords(body string, p Parser) ([]record, error) {
+	body = strings.TrimSpace(body)
+	if _, isCSV := p.(csvParser); !isCSV || !strings.Contains(body, "\n") {
+		s, err := p.Parse([]byte(body))
+		if err != nil {
+			return nil, err
+		}
+		return []record{{raw: body, stats: s}}, nil
+	}
+
+	var recs []record
+	seen := make(map[string]bool)
+	for n, line := range strings.Split(body, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		label, rest, _ := strings.Cut(line, ",")
+		label = strings.TrimSpace(label)
+		if label == "" {
+			return nil, fmt.Errorf("line %d: missing record id", n+1)
+		}
+		if seen[label] {
+			return nil, fmt.Errorf("line %d: duplicate record id %q", n+1, label)
+		}
+		seen[label] = true
+		s, err := p.Parse([]byte(rest))
+		if err != nil {
+			return nil, fmt.Errorf("record %s: %w", label, err)
+		}
+		recs = append(recs, record{label: label, raw: strings.TrimSpace(line), stats: s})
+	}
+	return recs, nil
+}
+
+// checkRecords проверяет записи ответа. Запись без метки идёт полным путём
+// (сглаживание, устаревание, всплески), записи с метками — только через
+// пороги и собственный трекер состояния; их алерты помечаются меткой.
+func (m *monitor) checkRecords(recs []record, now time.Time) []Alert {
+	opts := m.checkOptions()
+	var alerts []Alert
+	for _, rec := range recs {
+		if rec.label == "" {
+			for _, c := range m.capacity.observe(rec.stats) {
+				m.printf("Capacity change for %s: %d -> %d, baseline reset.", capacityMetrics[c.index], c.from, c.to)
+				m.ewma.reset(c.index)
+			}
+			as := checkStats(m.ewma.apply(rec.stats), now, opts)
+			as = confirmDisk(as, m.hist.recent(m.cfg.diskConfirmSamples), m.cfg.diskConfirmSamples, opts)
+			alerts = append(alerts, m.state.observe(as, now)...)
+			if a, ok := m.stale.check(rec.stats, now); ok {
+				alerts = append(alerts, a)
+			}
+			if a, ok := checkSpike(m.hist.recent(2), m.cfg.interval, m.cfg.spike); ok {
+				alerts = append(alerts, a)
+			}
+			continue
+		}
+		tr := m.recordStates[rec.label]
+		if tr == nil {
+			tr = newTracker(m.cfg.metricRecoveryConfirmPolls, m.cfg.escalateAfter)
+			m.recordStates[rec.label] = tr
+		}
+		alerts = append(alerts, labelAlerts(tr.observe(checkStats(rec.stats, now, opts), now), rec.label)...)
+	}
+	return alerts
+}
+
+// checkRecordsOnce — то же для разовых запусков: без состояния между опросами.
+func (m *monitor) checkRecordsOnce(recs []record, now time.Time) []Alert {
+	opts := m.checkOptions()
+	var alerts []Alert
+	for _, rec := range recs {
+		as := checkStats(rec.stats, now, opts)
+		if rec.label == "" {
+			if a, ok := m.stale.check(rec.stats, now); ok {
+				as = append(as, a)
+			}
+		}
+		alerts = append(alerts, labelAlerts(as, rec.label)...)
+	}
+	return alerts
+}
+
+// logRaw с VERBOSE печатает одной строкой исходные строки записей,
+// давших алерты, — чтобы отличить плохие данные от ошибки в проверке.
+func (m *monitor) logRaw(recs []record, alerts []Alert) {
+	if !m.cfg.verbose || len(alerts) == 0 {
+		return
+	}
+	var lines []string
+	for _, rec := range recs {
+		for _, a := range alerts {
+			if a.Record == rec.label {
+				lines = append(lines, rec.raw)
+				break
+			}
+		}
+	}
+	m.printf("Raw stats: %s", strings.Join(lines, " | "))
+}
+
+func labelPrefix(label string) string {
+	if label == "" {
+		return ""
+	}
+	return "[" + label + "] "
+}
+
+func labelAlerts(alerts []Alert, label string) []Alert {
+	if label == "" {
+		return alerts
+	}
+	for i := range alerts {
+		alerts[i].Record = label
+		alerts[i].Message = labelPrefix(label) + alerts[i].Message
+	}
+	return alerts
+}
+
+// anyBreached — нарушена ли хоть одна метрика хоть одной записи.
+func (m *monitor) anyBreached() bool {
+	if m.state.anyBreached() {
+		return true
+	}
+	for _, tr := range m.recordStates {
+		if tr.anyBreached() {
+			return true
+		}
+	}
+	return false
+}
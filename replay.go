@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// replayBatch — строки METRICS_JSONL одного опроса: записи многострочного
+// ответа пишутся с одним временем.
+type replayBatch struct {
+	at   time.Time
+	recs []record
+}
+
+// readReplay читает METRICS_JSONL и группирует строки по опросам.
+func readReplay(r io.Reader, loadPercent bool) ([]replayBatch, error) {
+	var batches []replayBatch
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var mr metricsRecord
+		if err := json.Unmarshal(sc.Bytes(), &mr); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if mr.Time.IsZero() {
+			return nil, fmt.Errorf("line %d: missing time", n)
+		}
+		// Эти поля в JSONL не пишутся
+		mr.Stats.LoadRaw = strconv.FormatFloat(mr.Stats.LoadAvg, 'f', -1, 64)
+		mr.Stats.LoadPercent = loadPercent
+
+		rec := record{label: mr.Record, raw: sc.Text(), stats: mr.Stats}
+		if last := len(batches) - 1; last >= 0 && batches[last].at.Equal(mr.Time) {
+			batches[last].recs = append(batches[last].recs, rec)
+			continue
+		}
+		batches = append(batches, replayBatch{at: mr.Time, recs: []record{rec}})
+	}
+	return batches, sc.Err()
+}
+
+// runReplay прогоняет записанные снимки через пороги и трекеры состояния
+// со временем из файла и печатает алерты, которые были бы разосланы,
+// с этим временем в начале строки. Сервер не опрашивается, получатели
+// из NOTIFIERS не используются.
+func (m *monitor) runReplay(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replay: %v\n", err)
+		return exitError
+	}
+	defer f.Close()
+	batches, err := readReplay(f, m.cfg.parse.loadPercent)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replay %s: %v\n", path, err)
+		return exitError
+	}
+
+	var at string
+	var n Notifier = textNotifier{
+		printf: func(severity string) func(format string, args ...any) {
+			printf := m.alertPrintf(severity)
+			return func(format string, args ...any) { printf("%s "+format, append([]any{at}, args...)...) }
+		},
+		durations: m.cfg.alertDurations,
+		explain:   m.explain,
+	}
+	if m.alertFmt == formatCEF {
+		n = cefNotifier{println: m.alertPrintln} // время уже в rt
+	}
+	m.sinks = []sink{{name: "stdout", n: n}}
+	fired := 0
+	for _, b := range batches {
+		at = b.at.Format(timeFormat)
+		m.hist.add(sample{At: b.at, Stats: b.recs[0].stats, Derived: derive(b.recs[0].stats, m.cfg.cpuCores)})
+		alerts := m.checkRecords(b.recs, b.at)
+		orderAlerts(alerts, m.cfg.alertOrder)
+		alerts, _ = m.flap.filter(alerts, b.at)
+		fired += countFiring(alerts)
+		m.dispatch(alerts)
+	}
+	m.printf("Replay finished: %d snapshots, %d alerts fired.", len(batches), fired)
+	return exitOK
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// retryBudget — token bucket на повторы, общий для всех опросов:
+// perMin токенов в минуту, не больше perMin в запасе.
+type retryBudget struct {
+	perMin float64 // 0 — без ограничения
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newRetryBudget(perMin int) *retryBudget {
+	return &retryBudget{perMin: float64(perMin), tokens: float64(perMin), last: time.Now()}
+}
+
+func (b *retryBudget) refill(now time.Time) {
+	b.tokens = math.Min(b.perMin, b.tokens+now.Sub(b.last).Minutes()*b.perMin)
+	b.last = now
+}
+
+// take забирает токен на один повтор; false — бюджет исчерпан.
+func (b *retryBudget) take() bool {
+	if b.perMin == 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// remaining — сколько повторов доступно сейчас; -1 — без ограничения.
+func (b *retryBudget) remaining() float64 {
+	if b.perMin == 0 {
+		return -1
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	return math.Floor(b.tokens)
+}
+
+// newLimiter: perSec == 0 — без ограничения.
+func newLimiter(perSec float64) *rate.Limiter {
+	if perSec == 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	return rate.NewLimiter(rate.Limit(perSec), 1)
+}
+
+// sleepCtx — time.Sleep, который прерывается отменой ctx.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// backoffDelay — пауза перед повтором attempt (с нуля): base, 2·base, 4·base, …
+func backoffDelay(base time.Duration, attempt int) time.Duration {
+	return base << attempt
+}
+
+// jitterDelay размывает паузу на ±jitter её длины, чтобы повторы многих
+// мониторов после общего сбоя не приходили разом.
+func jitterDelay(d time.Duration, jitter float64, r *rand.Rand) time.Duration {
+	if jitter <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + jitter*(2*r.Float64()-1)))
+}
+
+// newRand — источник разброса пауз. С RAND_SEED последовательность
+// воспроизводима; метка сервера в зерне разводит мониторы HOSTS.
+func newRand(seed int, server string) *rand.Rand {
+	if seed == 0 {
+		return rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+	h := fnv.New64a()
+	h.Write([]byte(server))
+	return rand.New(rand.NewPCG(uint64(seed), h.Sum64()))
+}
+
+// fetchWithRetry повторяет неудачный запрос до retries раз с удвоением паузы
+// (и разбросом RETRY_JITTER), пока хватает общего бюджета.
+func (m *monitor) fetchWithRetry() (string, error) {
+	body, err := m.fetcher.Fetch()
+	for attempt := 0; err != nil && retryable(err) && attempt < m.cfg.retries; attempt++ {
+		if !m.budget.take() {
+			return "", fmt.Errorf("%w (retry budget exhausted)", err)
+		}
+		delay := jitterDelay(backoffDelay(m.cfg.retryBackoff, attempt), m.cfg.retryJitter, m.rand)
+		if err := m.clock.Sleep(m.ctx, delay); err != nil {
+			return "", err
+		}
+		body, err = m.fetcher.Fetch()
+	}
+	return body, err
+}
+
+// pollWithDeadline — pollOnce под POLL_DEADLINE: запрос, повторы и паузы
+// между ними укладываются в один срок, и опрос не наезжает на следующий.
+// Запросы берут контекст из m.ctx, поэтому на время опроса он подменяется.
+func (m *monitor) pollWithDeadline() ([]record, error) {
+	if m.cfg.pollDeadline <= 0 {
+		return m.pollOnce()
+	}
+	parent := m.ctx
+	ctx, cancel := context.WithTimeout(parent, m.cfg.pollDeadline)
+	defer cancel()
+	m.ctx = ctx
+	defer func() { m.ctx = parent }()
+
+	recs, err := m.pollOnce()
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil {
+		m.printf("Poll abandoned: POLL_DEADLINE of %s exceeded (%v).", m.cfg.pollDeadline, err)
+		return nil, fmt.Errorf("poll deadline %s exceeded: %w", m.cfg.pollDeadline, err)
+	}
+	return recs, err
+}
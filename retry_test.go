@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"io"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sleepRecorder — Clock, который не ждёт, а запоминает паузы.
+type sleepRecorder struct {
+	sleeps []time.Duration
+}
+
+func (c *sleepRecorder) Now() time.Time { return time.Time{} }
+
+func (c *sleepRecorder) Sleep(_ context.Context, d time.Duration) error {
+	c.sleeps = append(c.sleeps, d)
+	return nil
+}
+
+func (c *sleepRecorder) After(time.Duration) (<-chan time.Time, func()) { return nil, func() {} }
+
+// retrySleeps — паузы fetchWithRetry, когда все попытки неудачны.
+func retrySleeps(t *testing.T, env map[string]string) []time.Duration {
+	t.Helper()
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newMonitor(cfg, io.Discard, new(sync.Mutex), nil)
+	clock := &sleepRecorder{}
+	m.clock = clock
+	m.fetcher = fetcherFunc(func() (string, error) { return "", errScripted })
+	if _, err := m.fetchWithRetry(); err == nil {
+		t.Fatal("fetchWithRetry succeeded, want error")
+	}
+	return clock.sleeps
+}
+
+func TestRetryBackoffDoubles(t *testing.T) {
+	got := retrySleeps(t, map[string]string{"FETCH_RETRIES": "3", "RETRY_BACKOFF": "100ms"})
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}
+	if !slices.Equal(got, want) {
+		t.Errorf("sleeps = %v, want %v", got, want)
+	}
+}
+
+func TestRetryJitterSeeded(t *testing.T) {
+	env := map[string]string{"FETCH_RETRIES": "5", "RETRY_BACKOFF": "100ms", "RETRY_JITTER": "0.5", "RAND_SEED": "42"}
+	got := retrySleeps(t, env)
+	if len(got) != 5 {
+		t.Fatalf("got %d sleeps, want 5", len(got))
+	}
+	jittered := false
+	for i, d := range got {
+		base := backoffDelay(100*time.Millisecond, i)
+		if d < base/2 || d > base*3/2 {
+			t.Errorf("sleep %d = %s, want within %s ± 50%%", i, d, base)
+		}
+		jittered = jittered || d != base
+	}
+	if !jittered {
+		t.Error("no sleep was jittered")
+	}
+	if again := retrySleeps(t, env); !slices.Equal(got, again) {
+		t.Errorf("same RAND_SEED gave %v, then %v", got, again)
+	}
+}
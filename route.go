@@ -0,0 +1,66 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// webhookRouteEnv — переменная отдельного вебхука метрики: DISK_WEBHOOK_URL.
+func webhookRouteEnv(metric string) string {
+	return strings.ToUpper(metric) + "_WEBHOOK_URL"
+}
+
+// loadWebhookRoutes читает <МЕТРИКА>_WEBHOOK_URL для встроенных метрик
+// и событий. nil — отдельных вебхуков нет.
+func loadWebhookRoutes() map[string]string {
+	var routes map[string]string
+	for _, metric := range slices.Concat(allMetrics, eventMetrics) {
+		if v := getenvString(webhookRouteEnv(metric), ""); v != "" {
+			if routes == nil {
+				routes = make(map[string]string)
+			}
+			routes[metric] = v
+		}
+	}
+	return routes
+}
+
+// webhookRouter отдаёт алерт вебхуку его метрики, а без него — общему
+// WEBHOOK_URL. Без общего вебхука алерты прочих метрик вебхуком не уходят.
+type webhookRouter struct {
+	routes   map[string]Notifier
+	fallback Notifier // nil — WEBHOOK_URL не задан
+}
+
+func (r webhookRouter) Notify(a Alert) error {
+	n, ok := r.routes[a.Metric]
+	if !ok {
+		n = r.fallback
+	}
+	if n == nil {
+		return nil
+	}
+	return n.Notify(a)
+}
+
+// drain ждёт все вебхуки одновременно, чтобы общее ожидание
+// не превысило timeout.
+func (r webhookRouter) drain(timeout time.Duration) {
+	all := []Notifier{r.fallback}
+	for _, n := range r.routes {
+		all = append(all, n)
+	}
+	var wg sync.WaitGroup
+	for _, n := range all {
+		if d, ok := n.(drainer); ok {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d.drain(timeout)
+			}()
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// rule — правило ALERT_RULES: булево выражение над полями Stats со своим
+// именем и уровнем. Дополняет встроенные проверки, а не заменяет их.
+type rule struct {
+	name     string
+	severity string
+	source   string
+	program  *vm.Program
+}
+
+var ruleNameRe = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// ruleFields — переменные выражений: поля Stats под именами из JSON,
+// доли в процентах (0–100) и поля EXTRA_FIELDS. Всё — float64.
+var ruleFields = []string{
+	"load_avg", "total_ram", "used_ram", "total_disk", "used_disk", "net_capacity", "net_used",
+	"mem_pct", "disk_pct", "net_pct",
+}
+
+// parseRules разбирает ALERT_RULES: правила через ";", каждое —
+// "имя[:уровень]=выражение", уровень crit (по умолчанию) или warn:
+//
+//	busy=mem_pct > 80 && load_avg > 20; hot:warn=temp > 70
+//
+// Выражения компилируются сразу: опечатка в имени поля — ошибка запуска.
+func parseRules(v string, extras []string) ([]rule, error) {
+	env := ruleEnv(Stats{}, extras, "")
+	var rules []rule
+	for _, def := range strings.Split(v, ";") {
+		if strings.TrimSpace(def) == "" {
+			continue
+		}
+		head, source, ok := strings.Cut(def, "=")
+		if !ok || strings.TrimSpace(source) == "" {
+			return nil, fmt.Errorf("expected name=expression, got %q", strings.TrimSpace(def))
+		}
+		name, severity, _ := strings.Cut(strings.TrimSpace(head), ":")
+		if severity == "" {
+			severity = severityCrit
+		}
+		switch {
+		case !ruleNameRe.MatchString(name):
+			return nil, fmt.Errorf("invalid rule name %q", name)
+		case slices.Contains(allMetrics, name) || slices.Contains(eventMetrics, name):
+			return nil, fmt.Errorf("rule %s: name is taken by a built-in metric", name)
+		case slices.ContainsFunc(rules, func(r rule) bool { return r.name == name }):
+			return nil, fmt.Errorf("duplicate rule %s", name)
+		case severity != severityCrit && severity != severityWarn:
+			return nil, fmt.Errorf("rule %s: severity must be %q or %q", name, severityCrit, severityWarn)
+		}
+		source = strings.TrimSpace(source)
+		program, err := expr.Compile(source, expr.Env(env), expr.AsBool())
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", name, err)
+		}
+		rules = append(rules, rule{name: name, severity: severity, source: source, program: program})
+	}
+	return rules, nil
+}
+
+func formatRules(rules []rule) string {
+	defs := make([]string, len(rules))
+	for i, r := range rules {
+		defs[i] = r.name + ":" + r.severity + "=" + r.source
+	}
+	return strings.Join(defs, "; ")
+}
+
+// ruleEnv — значения переменных для снимка. Доля без данных (total = 0)
+// и не присланное необязательное поле равны 0.
+func ruleEnv(s Stats, extras []string, memFormula string) map[string]any {
+	pct := func(used, total uint64) float64 {
+		if total == 0 {
+			return 0
+		}
+		return float64(used) * 100 / float64(total)
+	}
+	env := map[string]any{
+		"load_avg":     s.LoadAvg,
+		"total_ram":    float64(s.TotalRAM),
+		"used_ram":     float64(s.UsedRAM),
+		"total_disk":   float64(s.TotalDisk),
+		"used_disk":    float64(s.UsedDisk),
+		"net_capacity": float64(s.NetCapacity),
+		"net_used":     float64(s.NetUsed),
+		"mem_pct":      pct(s.memUsed(memFormula), s.TotalRAM),
+		"disk_pct":     pct(s.UsedDisk, s.TotalDisk),
+		"net_pct":      pct(s.NetUsed, s.NetCapacity),
+	}
+	for _, name := range extras {
+		if _, taken := env[name]; !taken {
+			env[name] = s.Extra[name]
+		}
+	}
+	return env
+}
+
+// matchedRules — сработавшие правила. Ошибка выполнения (её не поймала
+// компиляция) считается несрабатыванием.
+func matchedRules(s Stats, opts checkOptions) []rule {
+	if len(opts.rules) == 0 {
+		return nil
+	}
+	env := ruleEnv(s, opts.ruleExtras, opts.memFormula)
+	var matched []rule
+	for _, r := range opts.rules {
+		if res, err := expr.Run(r.program, env); err == nil && res == true {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
+
+// metricTitle — название метрики в сообщении о восстановлении;
+// у правил — имя правила.
+func metricTitle(metric string) string {
+	if t, ok := metricTitles[metric]; ok {
+		return t
+	}
+	return "Rule " + metric
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed fixtures/scenarios.txt
+var selftestScenarios string
+
+// scenario — сценарий цикла опроса: ответы на попытки запроса по опросам
+// и точный вывод монитора по порядку.
+type scenario struct {
+	name  string
+	line  int
+	env   []string // KEY=value поверх встроенных значений
+	steps []scenarioStep
+	want  []string
+}
+
+// scenarioStep — плановый опрос с ответами на попытки (тело или fail)
+// или переключение snooze, как по SIGUSR1.
+type scenarioStep struct {
+	replies []string
+	snooze  bool
+}
+
+func (sc scenario) polls() int {
+	n := 0
+	for _, st := range sc.steps {
+		if !st.snooze {
+			n++
+		}
+	}
+	return n
+}
+
+func parseScenarios(text string) ([]scenario, error) {
+	var all []scenario
+	for n, raw := range strings.Split(text, "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if name, ok := strings.CutPrefix(line, "== "); ok {
+			all = append(all, scenario{name: name, line: n + 1})
+			continue
+		}
+		if len(all) == 0 {
+			return nil, fmt.Errorf("line %d: expected \"== name\" first", n+1)
+		}
+		sc := &all[len(all)-1]
+		verb, rest, _ := strings.Cut(line, " ")
+		switch verb {
+		case "set":
+			sc.env = append(sc.env, strings.Fields(rest)...)
+		case "poll":
+			sc.steps = append(sc.steps, scenarioStep{replies: strings.Fields(rest)})
+		case "snooze":
+			sc.steps = append(sc.steps, scenarioStep{snooze: true})
+		case ">":
+			sc.want = append(sc.want, rest)
+		default:
+			return nil, fmt.Errorf("line %d: unknown step %q", n+1, verb)
+		}
+	}
+	return all, nil
+}
+
+// scriptedFetcher отдаёт ответы сценария по одному на попытку.
+type scriptedFetcher struct {
+	replies []string
+}
+
+var errScripted = errors.New("connection refused")
+
+func (f *scriptedFetcher) Fetch() (string, error) {
+	if len(f.replies) == 0 {
+		return "", errors.New("scenario: no reply left for this attempt")
+	}
+	r := f.replies[0]
+	f.replies = f.replies[1:]
+	if r == "fail" {
+		return "", errScripted
+	}
+	return r, nil
+}
+
+// manualClock двигается только паузами и шагами цикла; каждая пауза
+// попадает в вывод строкой "~ sleep 100ms", чтобы проверять повторы.
+// Таймер интервала срабатывает сразу, если next подготовил следующий
+// опрос, иначе не срабатывает никогда: next тогда завершает цикл.
+type manualClock struct {
+	now  time.Time
+	out  io.Writer
+	next func() bool
+}
+
+func (c *manualClock) Now() time.Time { return c.now }
+
+func (c *manualClock) Sleep(_ context.Context, d time.Duration) error {
+	fmt.Fprintf(c.out, "~ sleep %s\n", d)
+	c.now = c.now.Add(d)
+	return nil
+}
+
+func (c *manualClock) After(d time.Duration) (<-chan time.Time, func()) {
+	ch := make(chan time.Time, 1)
+	if c.next() {
+		c.now = c.now.Add(d)
+		ch <- c.now
+	}
+	return ch, func() {}
+}
+
+// runScenarios прогоняет сценарии цикла: монитор со встроенными
+// настройками и настройками сценария, ручными часами и ответами сценария
+// вместо сервера. Опросы идут через настоящий цикл run.
+func runScenarios(out io.Writer) (passed, failed int) {
+	all, err := parseScenarios(selftestScenarios)
+	if err != nil {
+		fmt.Fprintf(out, "FAIL scenarios: %v\n", err)
+		return 0, 1
+	}
+	for _, sc := range all {
+		os.Clearenv() // -selftest сразу завершается: окружение больше не нужно
+		for _, kv := range sc.env {
+			key, val, _ := strings.Cut(kv, "=")
+			os.Setenv(key, val)
+		}
+		got, err := sc.run()
+		if err != nil {
+			got = []string{"error: " + err.Error()}
+		}
+		if diff := scenarioDiff(sc.want, got); diff != "" {
+			failed++
+			fmt.Fprintf(out, "FAIL scenario %q (line %d): %s\n", sc.name, sc.line, diff)
+			continue
+		}
+		passed++
+		fmt.Fprintf(out, "PASS scenario %q: %d polls\n", sc.name, sc.polls())
+	}
+	return passed, failed
+}
+
+// run прогоняет сценарий с уже выставленным окружением (sc.env).
+func (sc scenario) run() ([]string, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	m := newMonitor(cfg, &buf, new(sync.Mutex), nil)
+	if err := m.buildSinks(cfg.notifiers); err != nil {
+		return nil, err
+	}
+	m.snooze = newSnooze(cfg.snoozeDuration, m.printf)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	steps := sc.steps
+	// next выполняет шаги до очередного опроса и готовит его ответы;
+	// шагов больше нет — цикл завершается.
+	next := func() bool {
+		for len(steps) > 0 {
+			st := steps[0]
+			steps = steps[1:]
+			if !st.snooze {
+				m.fetcher = &scriptedFetcher{replies: st.replies}
+				return true
+			}
+			m.snooze.toggle()
+		}
+		cancel()
+		return false
+	}
+	m.clock = &manualClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), out: &buf, next: next}
+	if cfg.pollSchedule == nil && !next() {
+		return nil, nil // опросов в сценарии нет
+	}
+	m.run(ctx, cfg.interval)
+	got := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if buf.Len() == 0 {
+		got = nil
+	}
+	return got, nil
+}
+
+// scenarioDiff — первое расхождение вывода с ожиданием; "" — совпал.
+func scenarioDiff(want, got []string) string {
+	for i := 0; i < max(len(want), len(got)); i++ {
+		switch {
+		case i >= len(got):
+			return fmt.Sprintf("output %d: want %q, got nothing", i+1, want[i])
+		case i >= len(want):
+			return fmt.Sprintf("output %d: unexpected %q", i+1, got[i])
+		case want[i] != got[i]:
+			return fmt.Sprintf("output %d: want %q, got %q", i+1, want[i], got[i])
+		}
+	}
+	return ""
+}
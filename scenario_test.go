@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestScenarios прогоняет сценарии fixtures/scenarios.txt через настоящий
+// цикл run, как -selftest, но с окружением через t.Setenv.
+func TestScenarios(t *testing.T) {
+	all, err := parseScenarios(selftestScenarios)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sc := range all {
+		t.Run(sc.name, func(t *testing.T) {
+			for _, kv := range sc.env {
+				key, val, _ := strings.Cut(kv, "=")
+				t.Setenv(key, val)
+			}
+			got, err := sc.run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := scenarioDiff(sc.want, got); diff != "" {
+				t.Errorf("line %d: %s", sc.line, diff)
+			}
+		})
+	}
+}
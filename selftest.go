@@ -0,0 +1,188 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed fixtures/selftest.txt
+var selftestFixtures string
+
+// fixture — строка fixtures/selftest.txt: парсер, ожидание с настройками
+// впереди и строка ответа сервера.
+type fixture struct {
+	line   int
+	parser string
+	want   string
+	body   string
+}
+
+func parseFixtures(text string) []fixture {
+	var all []fixture
+	for n, raw := range strings.Split(text, "\n") {
+		if strings.TrimSpace(raw) == "" || strings.HasPrefix(raw, "#") {
+			continue
+		}
+		want, line, ok := strings.Cut(raw, " | ")
+		if !ok {
+			want, line = strings.TrimSuffix(raw, " |"), ""
+		}
+		// "json:ok | {...}" — фикстура для другого парсера; \n в теле — перевод строки
+		parser := parserCSV
+		if name, rest, ok := strings.Cut(want, ":"); ok && parsers[name] != nil {
+			parser, want = name, rest
+		}
+		all = append(all, fixture{line: n + 1, parser: parser, want: want, body: strings.ReplaceAll(line, `\n`, "\n")})
+	}
+	return all
+}
+
+// check прогоняет фикстуру с настройками по умолчанию (окружение не
+// учитывается) и возвращает ожидание без настроек и полученный исход.
+func (f fixture) check() (want, got string, ok bool) {
+	parse := parseOptions{maxLoadAvg: defaultMaxLoadAvg, maxRatio: defaultMaxRatio}
+	parse.promMetrics, _ = parsePromMetrics("", namedCoreFields[:]) // поля под своими именами
+	c := checkOptions{
+		health:        healthOptions{weights: defaultHealthWeights()},
+		crit:          defaultCrit(),
+		netCombine:    combineEither,
+		loadPrecision: -1,
+	}
+	var req statsRequest
+	want, err := selftestSettings(f.want, &parse, &c, &req)
+	got = "error=" + fmt.Sprint(err) // ошибка настроек — как ошибка конфигурации
+	if err == nil {
+		got = selftestOutcome(parsers[f.parser](parse), f.body, c, req, strings.HasPrefix(want, "message="))
+	}
+	return want, got, matchesOutcome(want, got)
+}
+
+// runSelftest прогоняет парсеры и проверки порогов по встроенным
+// фикстурам с настройками по умолчанию (окружение не учитывается),
+// затем сценарии цикла опроса из fixtures/scenarios.txt.
+func runSelftest(out io.Writer) int {
+	passed, failed := 0, 0
+	for _, f := range parseFixtures(selftestFixtures) {
+		want, got, ok := f.check()
+		if ok {
+			passed++
+			fmt.Fprintf(out, "PASS %s %s: %q\n", f.parser, want, f.body)
+			continue
+		}
+		failed++
+		fmt.Fprintf(out, "FAIL line %d: %s %q: want %s, got %s\n", f.line, f.parser, f.body, want, got)
+	}
+
+	p, f := runScenarios(out)
+	passed, failed = passed+p, failed+f
+
+	fmt.Fprintf(out, "selftest: %d passed, %d failed\n", passed, failed)
+	if failed > 0 {
+		return exitSelftest
+	}
+	return exitOK
+}
+
+// selftestSettings применяет настройки в начале ожидания
+// ("EXTRA_FIELDS=mem_available MEM_FORMULA=used alerts=mem", LOAD_MODE=percent,
+// ALERT_RULES=busy=mem_pct>70&&load_avg>20 — правило без пробелов)
+// и возвращает остаток.
+func selftestSettings(want string, p *parseOptions, c *checkOptions, req *statsRequest) (string, error) {
+	for {
+		token, rest, _ := strings.Cut(want, " ")
+		key, val, ok := strings.Cut(token, "=")
+		if !ok || key != strings.ToUpper(key) {
+			return want, nil
+		}
+		switch key {
+		case "EXTRA_FIELDS":
+			p.extraFields = strings.Split(val, ",")
+		case "MEM_FORMULA":
+			c.memFormula = val
+		case "NET_INPUT_UNIT":
+			p.netUnit = 1
+			if val == netUnitBytes {
+				p.netUnit = 8
+			}
+		case "ALERT_RULES":
+			c.ruleExtras = p.extraFields
+			rules, err := parseRules(val, c.ruleExtras)
+			if err != nil {
+				return rest, fmt.Errorf("ALERT_RULES: %w", err)
+			}
+			c.rules = rules
+		case "TEMP_THRESHOLD", "STEAL_THRESHOLD", "IOWAIT_THRESHOLD":
+			v, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				return rest, fmt.Errorf("%s: %w", key, err)
+			}
+			switch key {
+			case "TEMP_THRESHOLD":
+				c.tempThreshold = v
+			case "STEAL_THRESHOLD":
+				c.stealThreshold = v
+			default:
+				c.iowaitThreshold = v
+			}
+		case "LOAD_PRECISION":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 0 {
+				return rest, fmt.Errorf("LOAD_PRECISION: invalid value %q", val)
+			}
+			c.loadPrecision = n
+		case "EMPTY_BODY":
+			req.skipEmpty = val == emptyBodySkip
+		case "LOAD_MODE":
+			p.loadPercent = val == loadModePercent
+			if p.loadPercent {
+				c.crit.load = loadPercentThreshold
+			}
+		default:
+			return want, nil
+		}
+		want = rest
+	}
+}
+
+// selftestOutcome — как у ответа с кодом 200: "nodata", если тело по
+// EMPTY_BODY означает «данных нет», иначе ошибка, "ok" или алерты;
+// с message — текст первого алерта.
+func selftestOutcome(p Parser, body string, check checkOptions, req statsRequest, message bool) string {
+	if noData(http.StatusOK, []byte(body), req.skipEmpty) {
+		return "nodata"
+	}
+	s, err := p.Parse([]byte(body))
+	if err != nil {
+		return "error=" + err.Error()
+	}
+	alerts := checkStats(s, time.Time{}, check)
+	if len(alerts) == 0 {
+		return "ok"
+	}
+	if message {
+		return "message=" + alerts[0].Message
+	}
+	names := make([]string, len(alerts))
+	for i, a := range alerts {
+		names[i] = a.Metric
+	}
+	return "alerts=" + strings.Join(names, ",")
+}
+
+func matchesOutcome(want, got string) bool {
+	if prefix, ok := strings.CutPrefix(want, "error="); ok {
+		msg, isErr := strings.CutPrefix(got, "error=")
+		return isErr && strings.HasPrefix(msg, prefix)
+	}
+	if names, ok := strings.CutPrefix(want, "alerts="); ok {
+		gotNames, isAlerts := strings.CutPrefix(got, "alerts=")
+		return isAlerts && slices.Equal(strings.Split(names, ","), strings.Split(gotNames, ","))
+	}
+	return want == got
+}
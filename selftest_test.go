@@ -0,0 +1,17 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestSelftest — фикстуры fixtures/selftest.txt, по подтесту на строку.
+func TestSelftest(t *testing.T) {
+	for _, f := range parseFixtures(selftestFixtures) {
+		t.Run(fmt.Sprintf("line%d", f.line), func(t *testing.T) {
+			if want, got, ok := f.check(); !ok {
+				t.Errorf("%s %q: want %s, got %s", f.parser, f.body, want, got)
+			}
+		})
+	}
+}
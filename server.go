@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// serverLabel выводит из адреса статистики короткое имя сервера для алертов:
+// хост без схемы и пути, порт — только если он указан явно. IPv6-адрес
+// с портом остаётся в скобках, чтобы двоеточия не смешивались:
+//
+//	http://srv.msk01.gigacorp.local/_stats → srv.msk01.gigacorp.local
+//	http://10.0.0.5:8080/_stats           → 10.0.0.5:8080
+//	https://[2001:db8::1]:8443/_stats     → [2001:db8::1]:8443
+//	http://[2001:db8::1]/_stats           → 2001:db8::1
+func serverLabel(rawURL string) string {
+	if rawURL == stdinURL {
+		return "stdin"
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		// Порта нет: SplitHostPort требует его, хост берётся как есть
+		return strings.Trim(u.Host, "[]")
+	}
+	if port == "" {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
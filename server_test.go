@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestServerLabel(t *testing.T) {
+	cases := []struct{ url, want string }{
+		{"http://10.0.0.1:8080/stats", "10.0.0.1:8080"},
+		{"http://[::1]:8080/stats", "[::1]:8080"},
+		{"http://[fe80::1]/stats", "fe80::1"},
+		{"http://srv.example.com", "srv.example.com"},
+		{"http://srv.example.com/", "srv.example.com"},
+		{"https://srv.example.com:8443/api/v1/stats?fmt=csv", "srv.example.com:8443"},
+		{"http://srv.example.com:/stats", "srv.example.com"},
+		{stdinURL, "stdin"},
+		{"srv.example.com", "srv.example.com"}, // без схемы — как есть
+	}
+	for _, c := range cases {
+		if got := serverLabel(c.url); got != c.want {
+			t.Errorf("serverLabel(%q) = %q, want %q", c.url, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !unix
+
+package main
+
+// SIGUSR1, SIGUSR2 и SIGHUP есть только на unix-системах
+func handleSignals(*monitor) {}
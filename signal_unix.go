@@ -0,0 +1,28 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handleSignals: SIGUSR1 переключает snooze, SIGUSR2 — внеочередной опрос,
+// SIGHUP перечитывает THRESHOLDS_FILE.
+func handleSignals(m *monitor) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGHUP)
+	go func() {
+		for sig := range ch {
+			switch sig {
+			case syscall.SIGUSR1:
+				m.snooze.toggle()
+			case syscall.SIGUSR2:
+				m.requestPoll()
+			case syscall.SIGHUP:
+				m.reloadThresholds()
+			}
+		}
+	}()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// snooze временно глушит алерты, не останавливая опрос.
+// Переключается сигналом; duration == 0 — до повторного переключения.
+type snooze struct {
+	duration time.Duration
+	logf     func(format string, args ...any)
+
+	mu         sync.Mutex
+	active     bool
+	suppressed int
+	timer      *time.Timer
+}
+
+func newSnooze(duration time.Duration, logf func(format string, args ...any)) *snooze {
+	return &snooze{duration: duration, logf: logf}
+}
+
+func (s *snooze) toggle() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active {
+		s.resumeLocked()
+		return
+	}
+	s.active = true
+	s.suppressed = 0
+	if s.duration > 0 {
+		s.timer = time.AfterFunc(s.duration, s.expire)
+		s.logf("Alerts snoozed for %s.", s.duration)
+		return
+	}
+	s.logf("Alerts snoozed.")
+}
+
+func (s *snooze) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active {
+		s.resumeLocked()
+	}
+}
+
+func (s *snooze) resumeLocked() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.active = false
+	s.logf("Alerts resumed, %d suppressed while snoozed.", s.suppressed)
+}
+
+// suppress учитывает n нарушений и сообщает, нужно ли их скрыть.
+func (s *snooze) suppress(n int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.active {
+		return false
+	}
+	s.suppressed += n
+	return true
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const metricLoadSpike = "load_spike"
+
+type spikeOptions struct {
+	factor float64 // во сколько раз load вырос с прошлого опроса; 0 — не проверять
+	delta  float64 // на сколько вырос; 0 — не проверять
+}
+
+func (o spikeOptions) enabled() bool { return o.factor > 0 || o.delta > 0 }
+
+// checkSpike сравнивает два последних снимка из истории и сообщает о резком
+// росте load average, даже если порог не превышен. Снимки, между которыми
+// прошло больше двух интервалов (были неудачные опросы), не сравниваются.
+func checkSpike(ss []sample, interval time.Duration, opts spikeOptions) (Alert, bool) {
+	if !opts.enabled() || len(ss) < 2 {
+		return Alert{}, false
+	}
+	prev, cur := ss[len(ss)-2], ss[len(ss)-1]
+	if cur.At.Sub(prev.At) > 2*interval {
+		return Alert{}, false
+	}
+	before, after := prev.Stats.LoadAvg, cur.Stats.LoadAvg
+	if after <= before {
+		return Alert{}, false
+	}
+	byFactor := opts.factor > 0 && before > 0 && after >= before*opts.factor
+	byDelta := opts.delta > 0 && after-before >= opts.delta
+	if !byFactor && !byDelta {
+		return Alert{}, false
+	}
+	return Alert{
+		Metric:   metricLoadSpike,
+		Status:   statusFiring,
+		Severity: severityWarn,
+		Message: fmt.Sprintf("Load Average spike: %s -> %s",
+			trimTrailingZeros(prev.Stats.LoadRaw), trimTrailingZeros(cur.Stats.LoadRaw)),
+		Value: after - before,
+		Time:  cur.At,
+	}, true
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type metricState struct {
+	breached  bool
+	okStreak  int       // опросов без нарушения подряд, пока нарушение не снято
+	since     time.Time // начало текущего состояния
+	lastValue float64
+	severity  string    // последний уровень нарушения; им же помечается восстановление
+	levelAt   time.Time // с какого опроса держится текущий уровень
+	escalated bool      // WARN повышен до CRIT по ESCALATE_AFTER
+}
+
+// tracker помнит состояние каждой метрики между опросами: с какого момента
+// она нарушена и когда восстановилась.
+type tracker struct {
+	mu      sync.Mutex
+	states  map[string]*metricState
+	confirm int       // восстановление — после стольких опросов без нарушения; 0 и 1 — сразу
+	started time.Time // первый опрос: с него отсчитывается норма ни разу не нарушенных метрик
+
+	escalateAfter map[string]time.Duration // ESCALATE_AFTER по метрикам
+}
+
+func newTracker(confirm int, escalateAfter map[string]time.Duration) *tracker {
+	return &tracker{states: make(map[string]*metricState), confirm: confirm, escalateAfter: escalateAfter}
+}
+
+// observe проставляет алертам начало нарушения и дописывает resolved-алерты
+// для метрик, которые были нарушены, а в этом опросе — нет.
+func (t *tracker) observe(alerts []Alert, now time.Time) []Alert {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.started.IsZero() {
+		t.started = now
+	}
+
+	firing := make(map[string]bool, len(alerts))
+	for i := range alerts {
+		a := &alerts[i]
+		firing[a.Metric] = true
+		st := t.states[a.Metric]
+		if st == nil || !st.breached {
+			st = &metricState{breached: true, since: now}
+			t.states[a.Metric] = st
+		}
+		t.escalate(a, st, now)
+		if st.levelAt.IsZero() || st.severity != a.Severity {
+			st.levelAt = now
+		}
+		st.lastValue = a.Value
+		st.severity = a.Severity
+		st.okStreak = 0
+		a.Since = st.since
+	}
+
+	for _, metric := range t.metrics() {
+		st := t.states[metric]
+		if st == nil || !st.breached || firing[metric] {
+			continue
+		}
+		if st.okStreak++; st.okStreak < t.confirm {
+			continue // ещё не подтверждено: метрика считается нарушенной
+		}
+		alerts = append(alerts, Alert{
+			Metric:   metric,
+			Status:   statusResolved,
+			Severity: st.severity,
+			Message:  fmt.Sprintf("%s back to normal after %s", metricTitle(metric), formatDuration(now.Sub(st.since))),
+			Value:    st.lastValue,
+			Time:     now,
+			Since:    st.since,
+		})
+		st.breached, st.since = false, now
+	}
+	return alerts
+}
+
+// metrics — встроенные метрики и затем правила ALERT_RULES, которые
+// хоть раз срабатывали, по имени. Вызывается под t.mu.
+func (t *tracker) metrics() []string {
+	var rules []string
+	for metric := range t.states {
+		if !slices.Contains(allMetrics, metric) {
+			rules = append(rules, metric)
+		}
+	}
+	slices.Sort(rules)
+	return append(slices.Clip(allMetrics), rules...)
+}
+
+// breached сообщает, нарушена ли метрика по последнему опросу.
+func (t *tracker) breached(metric string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.states[metric]
+	return st != nil && st.breached
+}
+
+// anyBreached сообщает, нарушена ли хоть одна метрика.
+func (t *tracker) anyBreached() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, st := range t.states {
+		if st.breached {
+			return true
+		}
+	}
+	return false
+}
+
+// formatDuration: 14m, 1h5m, 42s — без хвостовых нулей.
+func formatDuration(d time.Duration) string {
+	s := d.Round(time.Second).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
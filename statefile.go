@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stateStore — STATE_FILE: состояние трекеров всех серверов переживает
+// перезапуск. Мониторы отдают снимок после каждого опроса, файл
+// переписывается не чаще STATE_SAVE_INTERVAL и при завершении.
+type stateStore struct {
+	path     string
+	interval time.Duration
+
+	mu       sync.Mutex
+	servers  map[string]savedServer // по метке сервера
+	lastSave time.Time
+}
+
+type savedState struct {
+	SavedAt time.Time              `json:"saved_at"`
+	Servers map[string]savedServer `json:"servers"`
+}
+
+// savedServer — трекеры сервера по метке записи; "" — ответ без меток.
+type savedServer map[string]savedTracker
+
+type savedTracker struct {
+	Started time.Time              `json:"started"`
+	Metrics map[string]savedMetric `json:"metrics"`
+}
+
+type savedMetric struct {
+	Breached  bool      `json:"breached"`
+	OKStreak  int       `json:"ok_streak,omitempty"`
+	Since     time.Time `json:"since"`
+	LastValue float64   `json:"last_value"`
+	Severity  string    `json:"severity,omitempty"`
+	LevelAt   time.Time `json:"level_at"`
+	Escalated bool      `json:"escalated,omitempty"`
+}
+
+// loadState читает STATE_FILE. Файла ещё нет — состояния нет.
+func loadState(path string) (savedState, error) {
+	var st savedState
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	err = json.Unmarshal(data, &st)
+	return st, err
+}
+
+func newStateStore(path string, interval time.Duration, saved savedState) *stateStore {
+	servers := saved.Servers
+	if servers == nil {
+		servers = make(map[string]savedServer)
+	}
+	return &stateStore{path: path, interval: interval, servers: servers, lastSave: time.Now()}
+}
+
+// update запоминает снимок сервера и пишет файл, если с прошлой записи
+// прошло STATE_SAVE_INTERVAL.
+func (s *stateStore) update(server string, snap savedServer, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.servers[server] = snap
+	if now.Sub(s.lastSave) < s.interval {
+		return nil
+	}
+	return s.saveLocked(now)
+}
+
+func (s *stateStore) save(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saveLocked(now)
+}
+
+func (s *stateStore) saveLocked(now time.Time) error {
+	s.lastSave = now
+	return saveJSON(s.path, savedState{SavedAt: now, Servers: s.servers})
+}
+
+func (t *tracker) snapshot() savedTracker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	snap := savedTracker{Started: t.started, Metrics: make(map[string]savedMetric, len(t.states))}
+	for metric, st := range t.states {
+		snap.Metrics[metric] = savedMetric{
+			Breached:  st.breached,
+			OKStreak:  st.okStreak,
+			Since:     st.since,
+			LastValue: st.lastValue,
+			Severity:  st.severity,
+			LevelAt:   st.levelAt,
+			Escalated: st.escalated,
+		}
+	}
+	return snap
+}
+
+// restore подставляет сохранённое состояние до первого опроса. Сверка
+// с сервером — в первом же observe: нарушение, которое держится,
+// продолжается с прежним началом, а прошедшее за время простоя
+// восстанавливается обычным resolved.
+func (t *tracker) restore(snap savedTracker) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.started = snap.Started
+	for metric, sm := range snap.Metrics {
+		t.states[metric] = &metricState{
+			breached:  sm.Breached,
+			okStreak:  sm.OKStreak,
+			since:     sm.Since,
+			lastValue: sm.LastValue,
+			severity:  sm.Severity,
+			levelAt:   sm.LevelAt,
+			escalated: sm.Escalated,
+		}
+	}
+}
+
+// saveState отдаёт состояние трекеров в STATE_FILE. Вызывается из цикла:
+// recordStates меняется только там.
+func (m *monitor) saveState(now time.Time) {
+	if m.stateStore == nil {
+		return
+	}
+	snap := savedServer{"": m.state.snapshot()}
+	for label, tr := range m.recordStates {
+		snap[label] = tr.snapshot()
+	}
+	if err := m.stateStore.update(m.server, snap, now); err != nil {
+		m.printf("Unable to save state: %v", err)
+	}
+}
+
+// restoreState подставляет сохранённое состояние сервера и печатает,
+// какие нарушения продолжаются.
+func (m *monitor) restoreState(saved savedState) {
+	snap, ok := saved.Servers[m.server]
+	if !ok {
+		return
+	}
+	var breached []string
+	for label, st := range snap {
+		tr := m.state
+		if label != "" {
+			tr = newTracker(m.cfg.metricRecoveryConfirmPolls, m.cfg.escalateAfter)
+			m.recordStates[label] = tr
+		}
+		tr.restore(st)
+		for metric, sm := range st.Metrics {
+			if !sm.Breached {
+				continue
+			}
+			if label != "" {
+				metric = label + "/" + metric
+			}
+			breached = append(breached, metric)
+		}
+	}
+	sort.Strings(breached)
+	msg := "none breached"
+	if len(breached) > 0 {
+		msg = "breached: " + strings.Join(breached, ", ")
+	}
+	m.printf("Restored state saved %s ago (%s).", formatDuration(time.Since(saved.SavedAt)), msg)
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+const (
+	// Границы правдоподобия: за ними данные считаются испорченными
+	defaultMaxLoadAvg = 10000.0
+	defaultMaxRatio   = 1.0 // used/total
+)
+
+type Stats struct {
+	LoadAvg float64 `json:"load_avg"`
+	LoadRaw string  `json:"-"` // как пришло от сервера, для вывода
+	// LOAD_MODE=percent: LoadAvg — занятость CPU в процентах, а не load average
+	LoadPercent bool `json:"-"`
+
+	TotalRAM    uint64 `json:"total_ram"`
+	UsedRAM     uint64 `json:"used_ram"`
+	TotalDisk   uint64 `json:"total_disk"`
+	UsedDisk    uint64 `json:"used_disk"`
+	NetCapacity uint64 `json:"net_capacity"`
+	NetUsed     uint64 `json:"net_used"`
+
+	// Необязательные поля после основных семи, по именам из EXTRA_FIELDS
+	Extra map[string]float64 `json:"extra,omitempty"`
+
+	// Поля, отброшенные в нестрогом режиме разбора
+	Missing []string `json:"missing,omitempty"`
+
+	// Лишних полей в конце строки, пропущенных без STRICT_FIELDS
+	Ignored int `json:"-"`
+}
+
+const coreFields = 7
+
+// Известные необязательные поля
+const (
+	extraTimestamp = "timestamp" // unix-время снятия статистики на сервере, с
+	extraTemp      = "temp"      // температура CPU, °C
+	extraSteal     = "steal"     // время CPU, отнятое гипервизором, %
+	extraIOWait    = "iowait"    // время CPU в ожидании ввода-вывода, %
+
+	extraMemAvailable = "mem_available" // доступная память (MemAvailable), в единицах RAM_UNIT
+)
+
+var knownExtraFields = map[string]bool{
+	extraTimestamp:    true,
+	extraTemp:         true,
+	extraSteal:        true,
+	extraIOWait:       true,
+	extraMemAvailable: true,
+}
+
+// Имена полей 1–6 в сообщениях об ошибках
+var uintFieldNames = [...]string{"total RAM", "used RAM", "total disk", "used disk", "net capacity", "net used"}
+
+type parseOptions struct {
+	maxLoadAvg  float64
+	loadPercent bool // LOAD_MODE=percent: load в [0, 100]
+	maxRatio    float64
+	extraFields []string // имена полей 8, 9, ...; любое из них может отсутствовать
+
+	// lenient: неразобранное поле не отменяет весь опрос, а считается
+	// отсутствующим. Пара used/total без одной из половин обнуляется целиком,
+	// и проверка по ней пропускается, как при нулевом объёме.
+	lenient bool
+
+	// ignoreExtra: полей больше, чем основных и EXTRA_FIELDS, — лишние
+	// в конце пропускаются (STRICT_FIELDS=false); иначе это ошибка
+	ignoreExtra bool
+
+	// promMetrics — серии для полей в PARSER=prometheus, см. PROM_METRICS
+	promMetrics map[string]promSelector
+
+	// jsonSchema — дополнительная проверка тела в PARSER=json (JSON_SCHEMA); nil — нет
+	jsonSchema *jsonschema.Schema
+
+	// Множители в байты для полей RAM и диска (RAM_UNIT, DISK_UNIT_IN);
+	// 0 и 1 — поля уже в байтах
+	ramUnit, diskUnit uint64
+
+	// netUnit — множитель полей сети в бит/с: 8 для NET_INPUT_UNIT=bytes;
+	// 0 и 1 — поля уже в бит/с
+	netUnit uint64
+}
+
+// ParseStats разбирает строку вида
+// "load,totalRAM,usedRAM,totalDisk,usedDisk,netCap,netUsed[,extra...]".
+func ParseStats(line string, opts parseOptions) (Stats, error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return Stats{}, errors.New("empty body")
+	}
+
+	n := strings.Count(line, ",") + 1
+	// Запятая в конце («…,950,») даёт лишнее пустое поле: оно отбрасывается,
+	// если без него остаются все основные поля, — и с EXTRA_FIELDS, где оно
+	// пришлось бы на необязательное. Пустое основное поле — ошибка
+	if n > coreFields && strings.HasSuffix(line, ",") {
+		line, n = strings.TrimSpace(line[:len(line)-1]), n-1
+	}
+	ignored := 0
+	if known := coreFields + len(opts.extraFields); n > known && opts.ignoreExtra {
+		n, ignored = known, n-known
+	}
+	if n < coreFields || n > coreFields+len(opts.extraFields) {
+		return Stats{}, fmt.Errorf("unexpected fields count: %d", n)
+	}
+
+	// Поля разбираются по месту, без промежуточного []string:
+	// опрос идёт часто и по многим серверам, аллокации заметны.
+	rest := line
+	next := func() (string, bool) {
+		field, tail, _ := strings.Cut(rest, ",")
+		rest = tail
+		return strings.TrimSpace(field), true
+	}
+	s, err := parseFields(next, opts.extraFields[:n-coreFields], opts)
+	if err != nil {
+		return Stats{}, err
+	}
+	s.Ignored = ignored
+	return s, nil
+}
+
+// parseFields разбирает значения в порядке CSV: load avg, шесть объёмов,
+// затем extras. next возвращает следующее значение; false — поля нет
+// (бывает только у форматов с именами полей, см. parser.go).
+func parseFields(next func() (string, bool), extras []string, opts parseOptions) (Stats, error) {
+	s := Stats{LoadPercent: opts.loadPercent}
+
+	// 0: load avg
+	raw, present := next()
+	s.LoadRaw = raw
+	loadAvg, err := strconv.ParseFloat(s.LoadRaw, 64)
+	switch {
+	case !present && !opts.lenient:
+		return Stats{}, errors.New("missing load avg")
+	case err != nil && opts.lenient:
+		s.Missing = append(s.Missing, "load avg")
+		s.LoadRaw = ""
+	case err != nil:
+		return Stats{}, fieldError("load avg", s.LoadRaw, err)
+	default:
+		s.LoadAvg = loadAvg
+	}
+
+	// 1–6: остальные показатели
+	dst := [...]*uint64{&s.TotalRAM, &s.UsedRAM, &s.TotalDisk, &s.UsedDisk, &s.NetCapacity, &s.NetUsed}
+	var bad [len(dst)]bool
+	for i, d := range dst {
+		raw, present := next()
+		if !present && !opts.lenient {
+			return Stats{}, fmt.Errorf("missing %s", uintFieldNames[i])
+		}
+		v, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil && opts.lenient {
+			s.Missing = append(s.Missing, uintFieldNames[i])
+			bad[i] = true
+			continue
+		}
+		if err != nil {
+			return Stats{}, fieldError(uintFieldNames[i], raw, err)
+		}
+		*d = v
+	}
+	for i := 0; i < len(dst); i += 2 {
+		if bad[i] || bad[i+1] {
+			*dst[i], *dst[i+1] = 0, 0
+		}
+	}
+	if len(s.Missing) == 1+len(dst) {
+		return Stats{}, errors.New("no parseable fields")
+	}
+	units := [...]uint64{opts.ramUnit, opts.ramUnit, opts.diskUnit, opts.diskUnit, opts.netUnit, opts.netUnit}
+	for i, unit := range units {
+		if unit <= 1 {
+			continue
+		}
+		if *dst[i] > math.MaxUint64/unit {
+			into := "bytes"
+			if i >= 4 {
+				into = "bits"
+			}
+			return Stats{}, fmt.Errorf("%s overflows in %s: %d", uintFieldNames[i], into, *dst[i])
+		}
+		*dst[i] *= unit
+	}
+
+	// 7+: необязательные поля
+	for _, name := range extras {
+		raw, present := next()
+		if !present {
+			continue
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil && opts.lenient {
+			s.Missing = append(s.Missing, name)
+			continue
+		}
+		if err != nil {
+			return Stats{}, fieldError(name, raw, err)
+		}
+		if s.Extra == nil {
+			s.Extra = make(map[string]float64, len(opts.extraFields))
+		}
+		s.Extra[name] = v
+	}
+
+	if v, ok := s.Extra[extraMemAvailable]; ok && opts.ramUnit > 1 {
+		s.Extra[extraMemAvailable] = v * float64(opts.ramUnit)
+	}
+
+	if err := s.validate(opts); err != nil {
+		return Stats{}, err
+	}
+	return s, nil
+}
+
+// Длиннее этого неразобранное значение в ошибке обрезается
+const maxErrorValueLen = 32
+
+// fieldError: parse total RAM: invalid value "12.5GB": invalid syntax.
+// Значение экранируется, чтобы мусор из ответа не ломал строку лога.
+func fieldError(name, raw string, err error) error {
+	var ne *strconv.NumError
+	if errors.As(err, &ne) {
+		err = ne.Err // значение и так будет в сообщении
+	}
+	if len(raw) > maxErrorValueLen {
+		raw = raw[:maxErrorValueLen] + "..."
+	}
+	return fmt.Errorf("parse %s: invalid value %q: %w", name, raw, err)
+}
+
+func (s Stats) validate(opts parseOptions) error {
+	if s.LoadPercent && (math.IsNaN(s.LoadAvg) || s.LoadAvg < 0 || s.LoadAvg > 100) {
+		return fmt.Errorf("load percent out of range [0, 100]: %s", s.LoadRaw)
+	}
+	if math.IsNaN(s.LoadAvg) || s.LoadAvg < 0 || s.LoadAvg > opts.maxLoadAvg {
+		return fmt.Errorf("load avg out of range [0, %g]: %s", opts.maxLoadAvg, s.LoadRaw)
+	}
+	pairs := []struct {
+		name        string
+		used, total uint64
+	}{
+		{"RAM", s.UsedRAM, s.TotalRAM},
+		{"disk", s.UsedDisk, s.TotalDisk},
+		{"net", s.NetUsed, s.NetCapacity},
+	}
+	for _, p := range pairs {
+		if p.total == 0 {
+			continue // проверки по нулевому объёму всё равно пропускаются
+		}
+		if r := float64(p.used) / float64(p.total); r > opts.maxRatio {
+			return &ratioError{name: p.name, used: p.used, total: p.total, maxRatio: opts.maxRatio}
+		}
+	}
+	return nil
+}
+
+// Значения NET_INPUT_UNIT
+const (
+	netUnitBits  = "bits" // бит/с, как ждут автотесты: Мбит/с = значение / 1_000_000
+	netUnitBytes = "bytes"
+)
+
+func netUnitName(mult uint64) string {
+	if mult == 8 {
+		return netUnitBytes
+	}
+	return netUnitBits
+}
+
+// Значения LOAD_MODE
+const (
+	loadModeAvg     = "loadavg" // unix load average
+	loadModePercent = "percent" // занятость CPU, 0–100
+)
+
+func loadMode(percent bool) string {
+	if percent {
+		return loadModePercent
+	}
+	return loadModeAvg
+}
+
+// Формулы занятой памяти для проверки порога (MEM_FORMULA)
+const (
+	memFormulaAuto = "auto" // total − mem_available, если поле пришло, иначе used
+	memFormulaUsed = "used" // всегда used: кэш и буферы считаются занятыми
+)
+
+// memUsed — занятая память по формуле. Кэш ядра входит в used, но
+// освобождается по требованию, поэтому по mem_available алертов меньше.
+func (s Stats) memUsed(formula string) uint64 {
+	avail, ok := s.Extra[extraMemAvailable]
+	if formula == memFormulaUsed || !ok || avail < 0 || avail > float64(s.TotalRAM) {
+		return s.UsedRAM
+	}
+	return s.TotalRAM - uint64(avail)
+}
+
+// Timestamp — время снятия статистики на сервере, если поле есть.
+func (s Stats) Timestamp() (time.Time, bool) {
+	v, ok := s.Extra[extraTimestamp]
+	if !ok {
+		return time.Time{}, false
+	}
+	sec, frac := math.Modf(v)
+	return time.Unix(int64(sec), int64(frac*1e9)), true
+}
+
+func trimTrailingZeros(s string) string {
+	if !strings.Contains(s, ".") {
+		return s
+	}
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimRight(s, ".")
+	return s
+}
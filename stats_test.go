@@ -0,0 +1,108 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// parseStatsSplit — наглядный вариант ParseStats через strings.Split,
+// как до разбора по месту; держится для сравнения в тестах.
+func parseStatsSplit(line string, opts parseOptions) (Stats, error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return ParseStats(line, opts)
+	}
+	fields := strings.Split(line, ",")
+	known := coreFields + len(opts.extraFields)
+	if len(fields) > coreFields && strings.TrimSpace(fields[len(fields)-1]) == "" {
+		fields = fields[:len(fields)-1]
+	}
+	ignored := 0
+	if len(fields) > known && opts.ignoreExtra {
+		fields, ignored = fields[:known], len(fields)-known
+	}
+	if len(fields) < coreFields || len(fields) > known {
+		return ParseStats(line, opts) // та же ошибка о числе полей
+	}
+	i := 0
+	next := func() (string, bool) {
+		i++
+		return strings.TrimSpace(fields[i-1]), true
+	}
+	s, err := parseFields(next, opts.extraFields[:len(fields)-coreFields], opts)
+	if err != nil {
+		return Stats{}, err
+	}
+	s.Ignored = ignored
+	return s, nil
+}
+
+func testParseOptions() parseOptions {
+	return parseOptions{maxLoadAvg: defaultMaxLoadAvg, maxRatio: defaultMaxRatio}
+}
+
+func TestParseStatsMatchesSplit(t *testing.T) {
+	extras := testParseOptions()
+	extras.extraFields = []string{extraTimestamp, extraTemp}
+	lenient := extras
+	lenient.lenient = true
+	loose := testParseOptions()
+	loose.ignoreExtra = true
+
+	cases := []struct {
+		line string
+		opts parseOptions
+	}{
+		{testBody, testParseOptions()},
+		{" 2.25 , 8000 , 1000 , 100 , 10 , 1000 , 10 ", testParseOptions()},
+		{testBody + ",", testParseOptions()},
+		{testBody + ",", extras},
+		{testBody + ",1700000000,", extras},
+		{testBody + ",1700000000", extras},
+		{testBody + ",1700000000,61.5", extras},
+		{testBody + ",1700000000,hot", extras},
+		{testBody + ",1700000000,hot", lenient},
+		{"x,8000,1000,100,10,1000,10", lenient},
+		{testBody + ",1,2,3", loose},
+		{testBody + ",1,2,3", testParseOptions()},
+		{"1,2,3", testParseOptions()},
+		{"1.5,8000,,100,10,1000,10", testParseOptions()},
+		{"1.5,8000,9000,100,10,1000,10", testParseOptions()},
+		{"", testParseOptions()},
+	}
+	for _, c := range cases {
+		got, gotErr := ParseStats(c.line, c.opts)
+		want, wantErr := parseStatsSplit(c.line, c.opts)
+		if !reflect.DeepEqual(got, want) || errString(gotErr) != errString(wantErr) {
+			t.Errorf("%q:\n got %+v, %v\nwant %+v, %v", c.line, got, gotErr, want, wantErr)
+		}
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func BenchmarkParseStats(b *testing.B) {
+	opts := testParseOptions()
+	b.ReportAllocs()
+	for range b.N {
+		if _, err := ParseStats(testBody, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseStatsSplit(b *testing.B) {
+	opts := testParseOptions()
+	b.ReportAllocs()
+	for range b.N {
+		if _, err := parseStatsSplit(testBody, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
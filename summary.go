@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const metricSummary = "summary"
+
+// Значения SHUTDOWN_SUMMARY
+const (
+	summaryOff      = "off"
+	summaryPrint    = "print"    // напечатать в основной вывод
+	summaryDispatch = "dispatch" // разослать всем получателям, как heartbeat
+)
+
+// Состояния метрик в сводке
+const (
+	stateOK   = "OK"
+	stateWarn = "WARN"
+	stateCrit = "CRIT"
+)
+
+// stateOf — состояние метрики и его начало: при смене WARN на CRIT и обратно
+// отсчёт начинается заново. Метрика, ни разу не нарушенная, в норме с первого опроса.
+func (t *tracker) stateOf(metric string) (state string, since time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.states[metric]
+	switch {
+	case st == nil:
+		return stateOK, t.started
+	case !st.breached:
+		return stateOK, st.since
+	case st.severity == severityWarn:
+		return stateWarn, st.levelAt
+	default:
+		return stateCrit, st.levelAt
+	}
+}
+
+// metricValue — последнее значение метрики в единицах её порога:
+// load как есть, память, диск и сеть в процентах. ok == false — данных нет.
+func metricValue(s Stats, metric string, opts checkOptions) (string, bool) {
+	percent := func(used, total uint64) (string, bool) {
+		if total == 0 {
+			return "", false
+		}
+		return fmt.Sprintf("%d%%", used*100/total), true
+	}
+	switch metric {
+	case metricLoad:
+		return formatLoad(s, opts.loadPrecision), true
+	case metricMem:
+		return percent(s.memUsed(opts.memFormula), s.TotalRAM)
+	case metricDisk:
+		return percent(s.UsedDisk, s.TotalDisk)
+	case metricNet:
+		return percent(s.NetUsed, s.NetCapacity)
+	case metricTemp:
+		t, ok := s.Extra[extraTemp]
+		if !ok || opts.tempThreshold <= 0 {
+			return "", false
+		}
+		return strconv.FormatFloat(t, 'f', -1, 64) + "°C", true
+	case metricSteal, metricIOWait:
+		field, threshold := extraSteal, opts.stealThreshold
+		if metric == metricIOWait {
+			field, threshold = extraIOWait, opts.iowaitThreshold
+		}
+		v, ok := s.Extra[field]
+		if !ok || threshold <= 0 {
+			return "", false
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64) + "%", true
+	case metricHealth:
+		if opts.health.floor <= 0 {
+			return "", false
+		}
+		if score, ok := healthScore(s, opts); ok {
+			return fmt.Sprintf("%.0f", score), true
+		}
+	}
+	return "", false
+}
+
+// shutdownSummary — блок с состоянием каждой метрики на момент завершения:
+//
+//	Shutdown summary:
+//	  load  OK    for 1h5m  last 1.5
+//	  mem   CRIT  for 12m   last 85%
+//	  load p50 1.5, p95 2.8, p99 3.1 over 120 samples
+//
+// Метрики записей многострочного ответа идут с меткой: web1/mem.
+// ok == false, если не было ни одного успешного опроса.
+func (m *monitor) shutdownSummary(now time.Time) (string, bool) {
+	if len(m.lastRecs) == 0 {
+		return "", false
+	}
+	opts := m.checkOptions()
+	var rows [][4]string
+	for _, rec := range m.lastRecs {
+		tr := m.state
+		if rec.label != "" {
+			tr = m.recordStates[rec.label]
+		}
+		for _, metric := range allMetrics {
+			value, ok := metricValue(rec.stats, metric, opts)
+			if !ok {
+				continue
+			}
+			name := metric
+			if rec.label != "" {
+				name = rec.label + "/" + metric
+			}
+			state, since := tr.stateOf(metric)
+			rows = append(rows, [4]string{name, state, "for " + formatDuration(now.Sub(since)), "last " + value})
+		}
+	}
+
+	var width [3]int
+	for _, r := range rows {
+		for i := range width {
+			width[i] = max(width[i], len(r[i]))
+		}
+	}
+	var b strings.Builder
+	b.WriteString("Shutdown summary:")
+	for _, r := range rows {
+		fmt.Fprintf(&b, "\n  %-*s  %-*s  %-*s  %s", width[0], r[0], width[1], r[1], width[2], r[2], r[3])
+	}
+	if p, ok := m.hist.loadPercentiles(); ok {
+		b.WriteString("\n  " + p.String())
+	}
+	return b.String(), true
+}
+
+// reportShutdown печатает или рассылает сводку по SHUTDOWN_SUMMARY и при
+// любом SHUTDOWN_SUMMARY ждёт доставки фоновых получателей.
+func (m *monitor) reportShutdown(now time.Time) {
+	if text, ok := m.shutdownSummary(now); ok && m.cfg.shutdownSummary != summaryOff {
+		switch m.cfg.shutdownSummary {
+		case summaryPrint:
+			m.printf("%s", text)
+		case summaryDispatch:
+			m.dispatch([]Alert{{Metric: metricSummary, Status: statusOK, Message: text, Time: now}})
+		}
+	}
+	m.drainSinks()
+}
+
+// drainSinks отправляет накопленное получателями с фоновой доставкой:
+// очереди вебхуков, пачки NOTIFY_BATCH_WINDOW, группы FLEET_DEDUP.
+// Каждого ждёт не дольше NOTIFY_TIMEOUT.
+func (m *monitor) drainSinks() {
+	for _, s := range m.sinks {
+		if d, ok := s.n.(drainer); ok {
+			d.drain(m.cfg.notify.timeout)
+		}
+	}
+}
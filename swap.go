@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ratioError — used больше допустимой доли total.
+type ratioError struct {
+	name        string // RAM, disk, net
+	used, total uint64
+	maxRatio    float64
+}
+
+func (e *ratioError) Error() string {
+	return fmt.Sprintf("%s usage ratio out of range [0, %g]: %d/%d", e.name, e.maxRatio, e.used, e.total)
+}
+
+// swapDetector отличает разовый выброс used > total от систематического:
+// если так приходит polls опросов подряд, агент, вероятно, перепутал
+// порядок полей. Диагностика печатается один раз за эпизод.
+type swapDetector struct {
+	polls   int // 0 — выключено
+	name    string
+	streak  int
+	flagged bool
+}
+
+// observe учитывает результат разбора и возвращает диагностику, когда
+// серия достигла порога.
+func (d *swapDetector) observe(err error) (string, bool) {
+	var re *ratioError
+	if d.polls <= 0 || !errors.As(err, &re) || re.used <= re.total {
+		d.name, d.streak, d.flagged = "", 0, false
+		return "", false
+	}
+	if re.name != d.name {
+		d.name, d.streak, d.flagged = re.name, 0, false
+	}
+	d.streak++
+	if d.streak < d.polls || d.flagged {
+		return "", false
+	}
+	d.flagged = true
+	return fmt.Sprintf("Possible field order bug: %s used > total for %d polls in a row (%d/%d)",
+		re.name, d.streak, re.used, re.total), true
+}
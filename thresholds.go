@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// thresholdPatch — тело POST /config: меняются только указанные пороги.
+// load — как есть, остальное в целых процентах (90) или долей (0.9);
+// warn_* = 0 выключает предупреждение.
+type thresholdPatch struct {
+	LoadThreshold *float64      `json:"load_threshold,omitempty"`
+	MemThreshold  *patchPercent `json:"mem_threshold,omitempty"`
+	DiskThreshold *patchPercent `json:"disk_threshold,omitempty"`
+	NetThreshold  *patchPercent `json:"net_threshold,omitempty"`
+	WarnLoad      *float64      `json:"warn_load,omitempty"`
+	WarnMem       *patchPercent `json:"warn_mem,omitempty"`
+	WarnDisk      *patchPercent `json:"warn_disk,omitempty"`
+	WarnNet       *patchPercent `json:"warn_net,omitempty"`
+}
+
+// patchPercent — порог в процентах. Доля из (0, 1) переводится в проценты:
+// 0.9 — 90; дробные проценты (90.5) не принимаются. Сохраняется целым.
+type patchPercent int
+
+func (p *patchPercent) UnmarshalJSON(data []byte) error {
+	var v float64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("expected integer percent (90) or ratio (0.9), got %s", data)
+	}
+	if v > 0 && v < 1 {
+		v *= 100
+	}
+	r := math.Round(v)
+	if math.Abs(v-r) > 1e-9 {
+		return fmt.Errorf("expected integer percent (90) or ratio (0.9), got %s", data)
+	}
+	*p = patchPercent(r)
+	return nil
+}
+
+// thresholdSet — критические пороги и пороги предупреждения.
+type thresholdSet struct {
+	crit critThresholds
+	warn warnThresholds
+}
+
+func decodePatch(r io.Reader) (thresholdPatch, error) {
+	var p thresholdPatch
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return p, err
+	}
+	if p == (thresholdPatch{}) {
+		return p, errors.New("no thresholds given")
+	}
+	return p, nil
+}
+
+// apply возвращает пороги с изменениями из p или ошибку, если пара
+// warn/crit окажется несовместимой; opts при этом не меняется.
+func (p thresholdPatch) apply(opts checkOptions) (checkOptions, error) {
+	c, w := &opts.crit, &opts.warn
+	setFloat(&c.load, p.LoadThreshold)
+	setPercent(&c.mem, p.MemThreshold)
+	setPercent(&c.disk, p.DiskThreshold)
+	setPercent(&c.net, p.NetThreshold)
+	setFloat(&w.load, p.WarnLoad)
+	setPercent(&w.mem, p.WarnMem)
+	setPercent(&w.disk, p.WarnDisk)
+	setPercent(&w.net, p.WarnNet)
+
+	if c.load <= 0 {
+		return opts, errors.New("load_threshold must be positive")
+	}
+	for _, l := range [...]struct {
+		name   string
+		v, min int
+	}{
+		{"mem_threshold", c.mem, 1}, {"disk_threshold", c.disk, 1}, {"net_threshold", c.net, 1},
+		{"warn_mem", w.mem, 0}, {"warn_disk", w.disk, 0}, {"warn_net", w.net, 0},
+	} {
+		if l.v < l.min || l.v > 100 {
+			return opts, fmt.Errorf("%s must be in [%d, 100], got %d", l.name, l.min, l.v)
+		}
+	}
+	if w.load < 0 {
+		return opts, errors.New("warn_load must not be negative")
+	}
+	return opts, w.validate(*c)
+}
+
+func setFloat(dst *float64, v *float64) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+func setPercent(dst *int, v *patchPercent) {
+	if v != nil {
+		*dst = int(*v)
+	}
+}
+
+// merge дописывает к p заданные в q поля: так файл хранит все изменения,
+// а не только последнее.
+func (p thresholdPatch) merge(q thresholdPatch) thresholdPatch {
+	for _, f := range [...]struct{ dst, v **float64 }{{&p.LoadThreshold, &q.LoadThreshold}, {&p.WarnLoad, &q.WarnLoad}} {
+		if *f.v != nil {
+			*f.dst = *f.v
+		}
+	}
+	for _, f := range [...]struct{ dst, v **patchPercent }{
+		{&p.MemThreshold, &q.MemThreshold}, {&p.DiskThreshold, &q.DiskThreshold}, {&p.NetThreshold, &q.NetThreshold},
+		{&p.WarnMem, &q.WarnMem}, {&p.WarnDisk, &q.WarnDisk}, {&p.WarnNet, &q.WarnNet},
+	} {
+		if *f.v != nil {
+			*f.dst = *f.v
+		}
+	}
+	return p
+}
+
+// loadThresholds читает сохранённые в THRESHOLDS_FILE изменения.
+// Файла ещё нет — изменений нет.
+func loadThresholds(path string) (thresholdPatch, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return thresholdPatch{}, nil
+	}
+	if err != nil {
+		return thresholdPatch{}, err
+	}
+	return decodePatch(bytes.NewReader(data))
+}
+
+// saveJSON пишет v через временный файл, чтобы при сбое не остался
+// обрезанный JSON.
+func saveJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// thresholdValues — пороги по именам полей thresholdPatch, в порядке вывода.
+func thresholdValues(opts checkOptions) [][2]string {
+	c, w := opts.crit, opts.warn
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	i := strconv.Itoa
+	return [][2]string{
+		{"load_threshold", f(c.load)}, {"mem_threshold", i(c.mem)}, {"disk_threshold", i(c.disk)}, {"net_threshold", i(c.net)},
+		{"warn_load", f(w.load)}, {"warn_mem", i(w.mem)}, {"warn_disk", i(w.disk)}, {"warn_net", i(w.net)},
+	}
+}
+
+// thresholdChanges — изменившиеся пороги строками "mem_threshold 80 -> 90".
+func thresholdChanges(before, after checkOptions) []string {
+	var changes []string
+	was := thresholdValues(before)
+	for i, v := range thresholdValues(after) {
+		if v[1] != was[i][1] {
+			changes = append(changes, fmt.Sprintf("%s %s -> %s", v[0], was[i][1], v[1]))
+		}
+	}
+	return changes
+}
+
+// reloadThresholds — SIGHUP: THRESHOLDS_FILE перечитывается поверх порогов
+// из окружения на момент запуска. Изменения через POST /config без persist
+// при этом отменяются; с ошибкой в файле действующие пороги остаются.
+func (m *monitor) reloadThresholds() {
+	if m.cfg.thresholdsFile == "" {
+		m.printf("SIGHUP ignored: THRESHOLDS_FILE is not set.")
+		return
+	}
+	saved, err := loadThresholds(m.cfg.thresholdsFile)
+	m.cfgMu.Lock()
+	defer m.cfgMu.Unlock()
+	opts := m.cfg.check
+	opts.crit, opts.warn = m.cfg.envThresholds.crit, m.cfg.envThresholds.warn
+	if err == nil {
+		opts, err = saved.apply(opts)
+	}
+	if err != nil {
+		m.printf("Threshold reload failed, keeping current thresholds: THRESHOLDS_FILE: %v", err)
+		return
+	}
+	changes := thresholdChanges(m.cfg.check, opts)
+	m.cfg.check.crit, m.cfg.check.warn = opts.crit, opts.warn
+	if len(changes) == 0 {
+		m.printf("Thresholds reloaded on SIGHUP: no changes.")
+		return
+	}
+	m.printf("Thresholds reloaded on SIGHUP: %s.", strings.Join(changes, ", "))
+}
+
+// handleConfig: GET — текущая конфигурация; POST — изменить пороги.
+// С ?persist=true изменения сохраняются в THRESHOLDS_FILE и переживают
+// перезапуск, без него действуют до перезапуска или SIGHUP.
+func (m *monitor) handleConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		m.cfgMu.RLock()
+		dump := m.cfg.dump()
+		m.cfgMu.RUnlock()
+		writeJSON(w, http.StatusOK, dump)
+	case http.MethodPost:
+		m.updateThresholds(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (m *monitor) updateThresholds(w http.ResponseWriter, r *http.Request) {
+	persist, _ := strconv.ParseBool(r.URL.Query().Get("persist"))
+	if persist && m.cfg.thresholdsFile == "" {
+		http.Error(w, "persist requires THRESHOLDS_FILE", http.StatusBadRequest)
+		return
+	}
+	patch, err := decodePatch(r.Body)
+	if err != nil {
+		http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.cfgMu.Lock()
+	defer m.cfgMu.Unlock()
+	opts, err := patch.apply(m.cfg.check)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if persist {
+		saved, err := loadThresholds(m.cfg.thresholdsFile)
+		if err == nil {
+			err = saveJSON(m.cfg.thresholdsFile, saved.merge(patch))
+		}
+		if err != nil {
+			http.Error(w, "persist: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	changes := thresholdChanges(m.cfg.check, opts)
+	resp := make(map[string]json.Number)
+	for _, v := range thresholdValues(opts) {
+		resp[v[0]] = json.Number(v[1])
+	}
+	// Только пороги: веса здоровья читаются без блокировки
+	m.cfg.check.crit, m.cfg.check.warn = opts.crit, opts.warn
+	if len(changes) > 0 {
+		how := "until restart"
+		if persist {
+			how = "persisted"
+		}
+		m.printf("Thresholds changed via /config (%s): %s.", how, strings.Join(changes, ", "))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
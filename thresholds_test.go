@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDecodePatchPercentOrRatio(t *testing.T) {
+	cases := []struct {
+		body    string
+		want    int
+		wantErr string
+	}{
+		{body: `{"mem_threshold": 90}`, want: 90},
+		{body: `{"mem_threshold": 0.9}`, want: 90},
+		{body: `{"mem_threshold": 0.85}`, want: 85},
+		{body: `{"mem_threshold": 1}`, want: 1},
+		{body: `{"mem_threshold": 90.5}`, wantErr: "expected integer percent (90) or ratio (0.9), got 90.5"},
+		{body: `{"mem_threshold": 0.905}`, wantErr: "expected integer percent (90) or ratio (0.9), got 0.905"},
+		{body: `{"mem_threshold": "90%"}`, wantErr: `expected integer percent (90) or ratio (0.9), got "90%"`},
+	}
+	for _, c := range cases {
+		p, err := decodePatch(strings.NewReader(c.body))
+		switch {
+		case c.wantErr != "":
+			if err == nil || err.Error() != c.wantErr {
+				t.Errorf("%s: err = %v, want %s", c.body, err, c.wantErr)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", c.body, err)
+		case int(*p.MemThreshold) != c.want:
+			t.Errorf("%s: mem_threshold = %d, want %d", c.body, *p.MemThreshold, c.want)
+		}
+	}
+}
+
+func TestReloadThresholdsOnSIGHUP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "thresholds.json")
+	t.Setenv("THRESHOLDS_FILE", path)
+	t.Setenv("WARN_MEM", "50")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	m := newMonitor(cfg, &out, new(sync.Mutex), nil)
+	loadOnly := Stats{LoadAvg: 30}
+	if score, _ := healthScore(loadOnly, m.checkOptions()); score != 0 {
+		t.Errorf("health at load 30 of 30 = %v, want 0", score)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"load_threshold": 60, "warn_mem": 70}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m.reloadThresholds()
+	opts := m.checkOptions()
+	if opts.crit.load != 60 || opts.warn.mem != 70 {
+		t.Errorf("after reload crit.load = %v, warn.mem = %v, want 60 and 70", opts.crit.load, opts.warn.mem)
+	}
+	if score, _ := healthScore(loadOnly, opts); score != 50 {
+		t.Errorf("health at load 30 of 60 = %v, want 50", score)
+	}
+	if want := "Thresholds reloaded on SIGHUP: load_threshold 30 -> 60, warn_mem 50 -> 70.\n"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+
+	// Порог пропал из файла — снова действует значение из окружения
+	if err := os.WriteFile(path, []byte(`{"load_threshold": 60}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m.reloadThresholds()
+	if got := m.checkOptions().warn.mem; got != 50 {
+		t.Errorf("warn.mem after it left the file = %v, want 50 from WARN_MEM", got)
+	}
+
+	// Ошибка в файле не трогает действующие пороги
+	if err := os.WriteFile(path, []byte(`{"load_threshold": "x"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m.reloadThresholds()
+	if got := m.checkOptions().crit.load; got != 60 {
+		t.Errorf("after failed reload crit.load = %v, want 60", got)
+	}
+}
+
+func TestReloadThresholdsWithoutFile(t *testing.T) {
+	t.Setenv("THRESHOLDS_FILE", "")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	m := newMonitor(cfg, &out, new(sync.Mutex), nil)
+	m.reloadThresholds()
+	if want := "SIGHUP ignored: THRESHOLDS_FILE is not set.\n"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// byteUnits — допустимые значения RAM_UNIT и DISK_UNIT_IN: во сколько байт
+// единица поля. Регистр не важен.
+var byteUnits = map[string]uint64{
+	"b":   1,
+	"kb":  1000,
+	"mb":  1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+}
+
+func parseByteUnit(v string) (uint64, error) {
+	if v == "" {
+		return 1, nil
+	}
+	if u, ok := byteUnits[strings.ToLower(v)]; ok {
+		return u, nil
+	}
+	return 0, fmt.Errorf("unknown unit %q (want B, KB, MB, GB, KiB, MiB or GiB)", v)
+}
+
+func formatByteUnit(mult uint64) string {
+	for _, name := range []string{"b", "kib", "mib", "gib", "kb", "mb", "gb"} {
+		if byteUnits[name] == mult {
+			return strings.Replace(strings.ToUpper(name), "I", "i", 1)
+		}
+	}
+	return fmt.Sprint(mult)
+}
+
+// rateUnits — единицы свободной полосы в алерте сети (NET_OUTPUT_UNIT):
+// на сколько делить бит/с.
+var rateUnits = map[string]struct {
+	div   uint64
+	label string
+}{
+	"mbit":  {1_000_000, "Mbit/s"},
+	"gbit":  {1_000_000_000, "Gbit/s"},
+	"bytes": {8, "bytes/s"},
+}
+
+const defaultRateUnit = "mbit"
+
+func parseRateUnit(v string) (string, error) {
+	if v == "" {
+		return defaultRateUnit, nil
+	}
+	if _, ok := rateUnits[strings.ToLower(v)]; ok {
+		return strings.ToLower(v), nil
+	}
+	return "", fmt.Errorf("unknown unit %q (want Mbit, Gbit or bytes)", v)
+}
+
+// formatRate переводит бит/с в unit ("" — Mbit) с precision знаками после
+// точки: "50 Mbit/s", "1.25 Gbit/s". Лишние знаки отбрасываются, а не
+// округляются — как целые Mbit/s по умолчанию (toMbit).
+func formatRate(bits uint64, unit string, precision int) string {
+	u, ok := rateUnits[unit]
+	if !ok {
+		u = rateUnits[defaultRateUnit]
+	}
+	if precision <= 0 {
+		return strconv.FormatUint(bits/u.div, 10) + " " + u.label
+	}
+	scale := math.Pow10(precision)
+	v := math.Trunc(float64(bits)/float64(u.div)*scale) / scale
+	return strconv.FormatFloat(v, 'f', precision, 64) + " " + u.label
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// warmupConnection с WARMUP_CONNECTION до первого опроса делает HEAD-запрос
+// к STATS_URL: DNS, TCP и TLS устанавливаются заранее, и первый опрос идёт
+// по готовому соединению из пула клиента, а не платит за холодный старт
+// внутри HTTP_TIMEOUT_MS. Код ответа не важен, ошибка не мешает запуску.
+func (m *monitor) warmupConnection(ctx context.Context) {
+	if !m.cfg.warmupConnection || m.cfg.request.url == stdinURL {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, m.cfg.httpTimeout)
+	defer cancel()
+	release, err := m.pool.acquire(ctx)
+	if err != nil {
+		return
+	}
+	defer release()
+	if err := m.limiter.Wait(ctx); err != nil {
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, m.cfg.request.url, nil)
+	if err != nil {
+		return // адрес проверен validate; до опроса ошибка не дойдёт
+	}
+	start := time.Now()
+	resp, err := m.client.Do(req)
+	if err != nil {
+		m.printf("Connection warmup failed: %v", err)
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body) // иначе соединение не вернётся в пул
+	resp.Body.Close()
+	if m.cfg.verbose {
+		m.printf("Connection warmup: %s in %s.", resp.Status, time.Since(start).Round(time.Millisecond))
+	}
+}